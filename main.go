@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/encall/cpeevent-backend/src/controllers"
 	"github.com/encall/cpeevent-backend/src/routes"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -97,6 +98,21 @@ func main() {
 
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	if err := controllers.CreateIndexes(); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+
+	// Images saved inside documents before the media storage existed
+	if err := controllers.MigrateInlineImages(); err != nil {
+		log.Printf("Failed to migrate inline images: %v", err)
+	}
+
+	// Background jobs such as advancing event lifecycle states
+	go controllers.StartScheduler(time.Minute)
+
+	// Live results follow answers written by every instance when possible
+	go controllers.WatchAnswerChanges()
+
 	// Register all routes with /api prefix
 	api := r.Group("/api")
 	routes.UserRoutes(api)
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// membership describes how a student is attached to an event
+type membership struct {
+	StudentID     string
+	IsParticipant bool
+	IsStaff       bool
+	StaffRole     string
+	IsPresident   bool
+}
+
+// IsMember reports whether the student joined the event in any role
+func (m membership) IsMember() bool {
+	return m.IsParticipant || m.IsStaff
+}
+
+// IsOrganizer reports whether the student runs the event
+func (m membership) IsOrganizer() bool {
+	return m.IsStaff || m.IsPresident
+}
+
+func getMembership(event models.Event, studentID interface{}) membership {
+	var m membership
+	m.StudentID, _ = studentID.(string)
+	for _, participant := range event.Participants {
+		if participant == studentID {
+			m.IsParticipant = true
+			break
+		}
+	}
+	for _, staff := range event.Staff {
+		if staff.StdID == studentID {
+			m.IsStaff = true
+			m.StaffRole = staff.Role
+			break
+		}
+	}
+	if event.President != nil && *event.President == studentID {
+		m.IsPresident = true
+	}
+	return m
+}
+
+// visiblePostsFilter selects the posts of an event a member may read. Staff
+// see posts assigned to their role or everyone, participants public posts,
+// and everyone the tasks they are assigned to by name.
+func visiblePostsFilter(event models.Event, m membership) bson.M {
+	if m.IsStaff {
+		return bson.M{"_id": bson.M{"$in": event.PostList}, "$or": []bson.M{{"assignTo": m.StaffRole}, {"assignTo": "everyone"}, {"public": true}, {"assignees": m.StudentID}}}
+	}
+	return bson.M{"_id": bson.M{"$in": event.PostList}, "$or": []bson.M{{"public": true}, {"assignees": m.StudentID}}}
+}
+
+// postVisibleTo is visiblePostsFilter for a single post
+func postVisibleTo(post models.Post, m membership) bool {
+	if m.IsMember() && (post.Public || containsString(post.Assignees, m.StudentID)) {
+		return true
+	}
+	return m.IsStaff && (containsString(post.AssignTo, m.StaffRole) || containsString(post.AssignTo, "everyone"))
+}
+
+// readsPost reports whether a member of the event can read a post right
+// now, and with it its discussion and attachments
+func readsPost(post models.Post, m membership) bool {
+	if m.IsPresident {
+		return true
+	}
+	return postVisibleTo(post, m) && (m.IsOrganizer() || postLiveAt(post, postClock()))
+}
+
+// postAudience lists the members of an event who may read a post
+func postAudience(event models.Event, post models.Post) []string {
+	seen := make(map[string]bool)
+	var audience []string
+	members := append(append([]string{}, event.Participants...), staffIDs(event)...)
+	for _, studentID := range members {
+		if !seen[studentID] && postVisibleTo(post, getMembership(event, studentID)) {
+			seen[studentID] = true
+			audience = append(audience, studentID)
+		}
+	}
+	return audience
+}
+
+func staffIDs(event models.Event) []string {
+	ids := make([]string, 0, len(event.Staff))
+	for _, staff := range event.Staff {
+		ids = append(ids, staff.StdID)
+	}
+	return ids
+}
+
+// canReadAllAnswers reports whether the user may read the answers of any
+// student of the event, which is reserved to organizers and the department
+func canReadAllAnswers(m membership, access int) bool {
+	return access >= 3 || m.IsOrganizer()
+}
+
+// resultsAccess decides whether the user may see the aggregated results of a
+// post and whether the respondents are included. Organizers always see
+// everything, other members only what the author chose to publish.
+func resultsAccess(post models.Post, m membership, access int) (bool, bool) {
+	if canReadAllAnswers(m, access) {
+		return true, true
+	}
+	if !m.IsMember() {
+		return false, false
+	}
+
+	switch post.ResultsVisibility {
+	case models.ResultsVisibleDuring:
+		return true, false
+	case models.ResultsVisibleAfterClose:
+		return postClosed(post), false
+	default:
+		return false, false
+	}
+}
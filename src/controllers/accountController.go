@@ -1,15 +1,14 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"io"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -27,8 +26,8 @@ type UpdateAccountInfo struct { //You have to name the struct field according to
 }
 
 type User struct {
-	Username   string `json:"username" bson:"username"`
-	ImgProfile []byte `json:"imgProfile" bson:"imgProfile"`
+	Username   string  `json:"username" bson:"username"`
+	ImgProfile *string `json:"imgProfile" bson:"imgProfile"` // URL of the media item
 }
 
 func GetProfile() gin.HandlerFunc {
@@ -51,15 +50,17 @@ func GetProfile() gin.HandlerFunc {
 		}
 		defer cancel()
 
-		var imageBase64 string
-		if len(user.ImgProfile) > 0 {
-			imageBase64 = base64.StdEncoding.EncodeToString(user.ImgProfile)
+		var imgProfile, imgProfileThumb string
+		if user.ImgProfile != nil {
+			imgProfile = *user.ImgProfile
+			imgProfileThumb = imgProfile + "?size=thumb"
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"username": user.Username, "imgProfile": imageBase64}})
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"username": user.Username, "imgProfile": imgProfile, "imgProfileThumb": imgProfileThumb}})
 	}
 }
 
+// UpdateProfile changes the username and the profile image, both optional
 func UpdateProfile() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -70,13 +71,8 @@ func UpdateProfile() gin.HandlerFunc {
 			return
 		}
 
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMediaSize+1<<20)
 		username := c.PostForm("username")
-		file, err := c.FormFile("file")
-		if err != nil {
-			println("error here")
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 
 		updateFields := bson.M{}
 
@@ -84,40 +80,42 @@ func UpdateProfile() gin.HandlerFunc {
 			updateFields["username"] = username
 		}
 
-		if file != nil {
-			uploadFile, err := file.Open()
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to open image"})
-				log.Println("error here open")
+		var media *models.Media
+		if _, err := c.FormFile("file"); err != http.ErrMissingFile {
+			data, ok := readMediaUpload(c, "file")
+			if !ok {
 				return
 			}
-			defer uploadFile.Close()
-
-			var buf bytes.Buffer
-			_, err = io.Copy(&buf, uploadFile)
+			saved, err := saveMedia(ctx, models.MediaProfile, userID.(string), data)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read image"})
-				log.Println("error here read")
+				mediaError(c, err)
 				return
 			}
-
-			updateFields["imgProfile"] = buf.Bytes()
+			media = &saved
+			updateFields["imgProfile"] = mediaURL(saved.ID)
 		}
 
 		if len(updateFields) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "No update field provided"})
-			log.Println("error here no update")
 			return
 		}
 
-		result, err := userCollection.UpdateOne(ctx, bson.M{"studentID": userID}, bson.M{"$set": updateFields})
+		// Profiles saved before the media storage may still hold bytes
+		var previous bson.M
+		opts := options.FindOneAndUpdate().SetProjection(bson.M{"imgProfile": 1})
+		err := userCollection.FindOneAndUpdate(ctx, bson.M{"studentID": userID}, bson.M{"$set": updateFields}, opts).Decode(&previous)
 		if err != nil {
+			if media != nil {
+				deleteMedia(ctx, media.ID)
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			log.Println("error here insert")
 			return
 		}
+		if url, ok := previous["imgProfile"].(string); ok && media != nil {
+			replaceMedia(ctx, &url)
+		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "Account info updated successfully"})
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": updateFields, "message": "Account info updated successfully"})
 	}
 }
 
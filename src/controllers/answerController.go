@@ -3,6 +3,7 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -18,93 +19,348 @@ import (
 )
 
 var transactionCollection *mongo.Collection = database.OpenCollection(database.Client, "transactions")
+var answerHistoryCollection *mongo.Collection = database.OpenCollection(database.Client, "answerHistory")
 
 type QuestionForm struct {
 	QuestionIndex int    `json:"questionIndex"`
 	Question      string `json:"question"`
 }
 
+// bindAnswerPost reads the request body and loads the post it answers. The
+// body is returned so it can be decoded again for the kind of the post.
+func bindAnswerPost(c *gin.Context, ctx context.Context) (models.Post, []byte, bool) {
+	var post models.Post
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return post, nil, false
+	}
+
+	// Re-bind the request body
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	// Parse the request body to get the postID
+	var request struct {
+		PostID primitive.ObjectID `json:"postID"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return post, nil, false
+	}
+
+	// Query the post by its ID
+	if err := postCollection.FindOne(ctx, bson.M{"_id": request.PostID}).Decode(&post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Post not found"})
+		return post, nil, false
+	}
+	if !postLiveAt(post, postClock()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return post, nil, false
+	}
+
+	return post, body, true
+}
+
+// bindAnswer decodes and validates the answer in the body for the kind of
+// post. The answer is always stored under the logged in student.
+func bindAnswer(c *gin.Context, post models.Post, body []byte, id primitive.ObjectID, studentID string, meta models.AnswerMeta) (interface{}, bool) {
+	// Re-bind the request body again for the answer
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	kind, err := lookupAnswerKind(post.Kind)
+	if err != nil {
+		rejectPostKind(c, post.Kind, err)
+		return nil, false
+	}
+	return kind.bindAnswer(c, post, id, studentID, meta)
+}
+
 func SubmitAnswer() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure cancel is called to release resources
 
-		// Log the request body
-		body, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
 			return
 		}
 
-		// Re-bind the request body
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-
-		// Parse the request body to get the postID
-		var request struct {
-			PostID primitive.ObjectID `json:"postID"`
-		}
-		if err := c.BindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		post, body, ok := bindAnswerPost(c, ctx)
+		if !ok {
 			return
 		}
 
-		// Query the post by its ID
-		var post models.Post
-		if err := postCollection.FindOne(ctx, bson.M{"_id": request.PostID}).Decode(&post); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Post not found"})
+		if rejectLateAnswer(c, post, userID.(string)) {
 			return
 		}
 
-		switch post.Kind {
-		case "vote":
-			var voteRequest models.AVote
-
-			// Re-bind the request body again for voteRequest
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-
-			if err := c.BindJSON(&voteRequest); err != nil {
+		// A draft is finalized in place, with its answers when none are sent
+		id := primitive.NewObjectID()
+		previous, err := findOwnAnswer(ctx, post.ID, userID.(string))
+		if err != nil && err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		fromDraft := err == nil && previous.Status == models.AnswerStatusDraft
+		if fromDraft {
+			id = previous.ID
+			if body, err = withDraftAnswers(body, previous); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+		}
 
-			voteRequest.ID = primitive.NewObjectID()
+		now := time.Now().UTC()
+		meta := models.AnswerMeta{Status: models.AnswerStatusSubmitted, SubmittedAt: now, UpdatedAt: now, Version: 1}
+		answer, ok := bindAnswer(c, post, body, id, userID.(string), meta)
+		if !ok {
+			return
+		}
 
-			// Insert the vote request into transactions
-			_, err = transactionCollection.InsertOne(ctx, voteRequest)
+		if vote, isVote := answer.(models.AVote); isVote && post.Anonymous {
+			castAnonymousVote(c, ctx, post, vote)
+			return
+		}
+
+		if fromDraft {
+			result, err := transactionCollection.ReplaceOne(ctx, bson.M{"_id": id, "status": models.AnswerStatusDraft}, answer)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if result.MatchedCount == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Answer already submitted, edit it instead"})
+				return
+			}
+		} else {
+			// The unique index on (postID, studentID) rejects a second answer
+			_, err := transactionCollection.InsertOne(ctx, answer)
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Answer already submitted, edit it instead"})
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+		}
 
-		case "form":
-			var formRequest models.AForm
+		notifyAnswerChange(post.ID)
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "answer submitted"})
+	}
+}
 
-			// Re-bind the request body again for formRequest
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+// EditAnswer replaces the answer of the logged in student, keeping the
+// previous version in the answer history
+func EditAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-			if err := c.BindJSON(&formRequest); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
 
-			formRequest.ID = primitive.NewObjectID()
+		post, body, ok := bindAnswerPost(c, ctx)
+		if !ok {
+			return
+		}
 
-			// Insert the form request into transactions
-			_, err = transactionCollection.InsertOne(ctx, formRequest)
-			if err != nil {
+		if rejectLateAnswer(c, post, userID.(string)) {
+			return
+		}
+
+		if post.Anonymous {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Anonymous ballots cannot be changed"})
+			return
+		}
+
+		previous, err := findOwnAnswer(ctx, post.ID, userID.(string))
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No answer to edit"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if previous.Status == models.AnswerStatusDraft {
+			c.JSON(http.StatusConflict, gin.H{"error": "Answer is a draft, submit it instead"})
+			return
+		}
+
+		meta := models.AnswerMeta{Status: models.AnswerStatusSubmitted, SubmittedAt: previous.SubmittedAt, UpdatedAt: time.Now().UTC(), Version: previous.Version + 1}
+		answer, ok := bindAnswer(c, post, body, previous.ID, userID.(string), meta)
+		if !ok {
+			return
+		}
+
+		if err := archiveAnswer(ctx, previous, models.AnswerActionEdit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Matching the version makes a concurrent edit fail instead of being lost
+		result, err := transactionCollection.ReplaceOne(ctx, bson.M{"_id": previous.ID, "version": previous.rawVersion}, answer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Answer was changed by another request, try again"})
+			return
+		}
+
+		notifyAnswerChange(post.ID)
+		if form, isForm := answer.(models.AForm); isForm {
+			answer = hideScore(post, form, userID.(string))
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": answer, "message": "answer updated"})
+	}
+}
+
+// WithdrawAnswer removes the answer of the logged in student
+func WithdrawAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		var post models.Post
+		if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return
+		}
+
+		if rejectLateAnswer(c, post, userID.(string)) {
+			return
+		}
+
+		if post.Anonymous {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Anonymous ballots cannot be withdrawn"})
+			return
+		}
+
+		previous, err := findOwnAnswer(ctx, postID, userID.(string))
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No answer to withdraw"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// A draft was never submitted, it is discarded without history
+		if previous.Status == models.AnswerStatusDraft {
+			if _, err := transactionCollection.DeleteOne(ctx, bson.M{"_id": previous.ID, "status": models.AnswerStatusDraft}); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": "draft discarded"})
+			return
+		}
 
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown post kind"})
+		if err := archiveAnswer(ctx, previous, models.AnswerActionWithdraw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "data": "answer submitted"})
+		if _, err := transactionCollection.DeleteOne(ctx, bson.M{"_id": previous.ID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		notifyAnswerChange(postID)
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "answer withdrawn"})
 	}
 }
 
+// storedAnswer is an answer of any kind as it is in the transactions collection
+type storedAnswer struct {
+	ID          primitive.ObjectID
+	Status      string
+	SubmittedAt time.Time
+	Version     int
+	rawVersion  interface{} // as stored, missing on answers from before versioning
+	doc         bson.Raw
+}
+
+func findOwnAnswer(ctx context.Context, postID primitive.ObjectID, studentID string) (storedAnswer, error) {
+	var answer storedAnswer
+
+	raw, err := transactionCollection.FindOne(ctx, bson.M{"postID": postID, "studentID": studentID}).Raw()
+	if err != nil {
+		return answer, err
+	}
+	return storedAnswerFromRaw(raw)
+}
+
+func storedAnswerFromRaw(raw bson.Raw) (storedAnswer, error) {
+	var answer storedAnswer
+
+	var meta struct {
+		ID                primitive.ObjectID `bson:"_id"`
+		models.AnswerMeta `bson:",inline"`
+	}
+	if err := bson.Unmarshal(raw, &meta); err != nil {
+		return answer, err
+	}
+
+	answer.ID = meta.ID
+	answer.Status = meta.Status
+	answer.SubmittedAt = meta.SubmittedAt
+	answer.Version = meta.Version
+	answer.rawVersion = bson.M{"$exists": false}
+	if _, err := raw.LookupErr("version"); err == nil {
+		answer.rawVersion = meta.Version
+	}
+	if answer.Version == 0 {
+		answer.Version = 1
+	}
+	answer.doc = raw
+	return answer, nil
+}
+
+// archiveAnswer keeps a copy of an answer before it is replaced or removed
+func archiveAnswer(ctx context.Context, answer storedAnswer, action string) error {
+	var owner struct {
+		PostID    primitive.ObjectID `bson:"postID"`
+		StudentID string             `bson:"studentID"`
+	}
+	if err := bson.Unmarshal(answer.doc, &owner); err != nil {
+		return err
+	}
+
+	revision := models.AnswerRevision{
+		ID:         primitive.NewObjectID(),
+		AnswerID:   answer.ID,
+		PostID:     owner.PostID,
+		StudentID:  owner.StudentID,
+		Version:    answer.Version,
+		Action:     action,
+		Answer:     answer.doc,
+		ArchivedAt: time.Now().UTC(),
+	}
+	_, err := answerHistoryCollection.InsertOne(ctx, revision)
+	return err
+}
+
 func GetUserAnswer() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -132,244 +388,240 @@ func GetUserAnswer() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Post not found"})
 			return
 		}
-		log.Print(post.Kind)
-		switch post.Kind {
-		case "vote":
-			var vote models.AVote
-			if err := transactionCollection.FindOne(ctx, bson.M{"postID": request.PostID, "studentID": request.StudentID}).Decode(&vote); err != nil {
-				if err == mongo.ErrNoDocuments {
-					c.JSON(http.StatusOK, gin.H{"success": true, "data": nil})
-					return
-				}
-				log.Printf("Error finding vote: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Vote not found"})
+
+		// Students may read their own answer, organizers everyone's
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+		if userID != request.StudentID {
+			event, err := findPostEvent(ctx, request.PostID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 				return
 			}
-			c.JSON(http.StatusOK, gin.H{"success": true, "data": vote})
-
-		case "form":
-			var form models.AForm
-			query := bson.M{"postID": request.PostID, "studentID": request.StudentID}
-			if err := transactionCollection.FindOne(ctx, query).Decode(&form); err != nil {
-				if err == mongo.ErrNoDocuments {
-					c.JSON(http.StatusOK, gin.H{"success": true, "data": nil})
-					return
-				}
-				log.Printf("Error finding form: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Form not found"})
+			if !canReadAllAnswers(getMembership(event, userID), access.(int)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You can only read your own answers"})
 				return
 			}
+		}
+		kind, err := lookupAnswerKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+		answer, err := kind.readAnswer(ctx, post, request.StudentID, userID == request.StudentID)
+		if err != nil {
+			log.Printf("Error finding answer: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Answer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": answer})
+	}
+}
+
+func GetSummaryAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure cancel is called to release resources
 
-			c.JSON(http.StatusOK, gin.H{"success": true, "data": form})
+		postIDParam := c.Param("postID")
+		postID, err := primitive.ObjectIDFromHex(postIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID"})
+			return
+		}
+
+		post, withRespondents, ok := loadSummaryPost(c, ctx, postID)
+		if !ok {
+			return
 		}
+		if _, err := lookupAnswerKind(post.Kind); err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+
+		response, err := buildSummary(ctx, post, withRespondents)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": response})
 	}
 }
 
-// get Answer option from passing postID
-func GetAnswerOptionInVote(postID primitive.ObjectID) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+var (
+	errEventNotFound      = errors.New("Event not found")
+	errResultsUnavailable = errors.New("Results of this post are not available")
+	errOpenAnonymousVote  = errors.New("Results of an anonymous vote are shown once it closes")
+)
+
+// loadSummaryPost fetches a post and checks the user may see its results.
+// The flag tells whether the respondents may be shown as well.
+func loadSummaryPost(c *gin.Context, ctx context.Context, postID primitive.ObjectID) (models.Post, bool, bool) {
+	userID, _ := c.Get("studentid")
+	access, _ := c.Get("access")
+
+	post, withRespondents, err := summaryAccess(ctx, postID, userID, access.(int))
+	switch err {
+	case nil:
+		return post, withRespondents, true
+	case errEventNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errResultsUnavailable, errOpenAnonymousVote:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return post, false, false
+}
 
+// summaryAccess is loadSummaryPost without the response, for checks made
+// after the response has started
+func summaryAccess(ctx context.Context, postID primitive.ObjectID, userID interface{}, access int) (models.Post, bool, error) {
 	var post models.Post
 	if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
-		return nil, err
+		return post, false, err
 	}
 
-	if post.Kind != "vote" {
-		return nil, nil
+	event, err := findPostEvent(ctx, postID)
+	if err != nil {
+		return post, false, errEventNotFound
 	}
 
-	var votes []models.AVote
-	query := bson.M{"postID": postID}
-	cursor, err := transactionCollection.Find(ctx, query)
+	allowed, withRespondents := resultsAccess(post, getMembership(event, userID), access)
+	if !allowed {
+		return post, false, errResultsUnavailable
+	}
+	// Watching the tally move as each vote comes in would tell who voted what
+	if post.Anonymous && !postClosed(post) {
+		return post, false, errOpenAnonymousVote
+	}
+	return post, withRespondents, nil
+}
+
+// buildSummary aggregates the answers of a post. Without respondents the
+// result holds no studentIDs.
+func buildSummary(ctx context.Context, post models.Post, withRespondents bool) (interface{}, error) {
+	kind, err := lookupAnswerKind(post.Kind)
 	if err != nil {
 		return nil, err
 	}
+	return kind.summarize(ctx, post, withRespondents)
+}
 
-	defer cursor.Close(ctx)
-
-	if err := cursor.All(ctx, &votes); err != nil {
+func summarizeVote(ctx context.Context, post models.Post) (interface{}, error) {
+	votes, err := loadVoteChoices(ctx, post)
+	if err != nil {
 		return nil, err
 	}
 
-	optionSet := make(map[string]struct{})
-	for _, vote := range votes {
-		optionSet[string(vote.Answer)] = struct{}{}
+	questions := ballotQuestions(post)
+	results := make([]voteQuestionResult, 0, len(questions))
+	for i, question := range questions {
+		results = append(results, tallyVoteQuestion(i, question, votes))
 	}
 
-	var options []string
-	for option := range optionSet {
-		options = append(options, option)
+	// totalVotes and results describe the first question, as they did before
+	// ballots had several questions
+	response := struct {
+		TotalVotes int                  `json:"totalVotes"`
+		Results    []voteOptionCount    `json:"results"`
+		Questions  []voteQuestionResult `json:"questions"`
+		Audit      *voteAudit           `json:"audit,omitempty"`
+	}{
+		TotalVotes: results[0].TotalBallots,
+		Results:    results[0].Results,
+		Questions:  results,
 	}
-	// sort the options
-	sort.Strings(options)
-	// log.Print(options)
-
-	return options, nil
-}
 
-func GetSummaryAnswer() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel() // Ensure cancel is called to release resources
-
-		postIDParam := c.Param("postID")
-		postID, err := primitive.ObjectIDFromHex(postIDParam)
+	if post.Anonymous {
+		response.Audit, err = auditAnonymousVote(ctx, post.ID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID"})
+			return nil, err
 		}
+	}
 
-		var post models.Post
-		if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		switch post.Kind {
-		case "vote":
-			options, err := GetAnswerOptionInVote(postID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-
-			aggregateQuery := mongo.Pipeline{
-				{{"$match", bson.D{{Key: "postID", Value: postID}}}},
-				{{"$group", bson.D{
-					{Key: "_id", Value: "$answer"},
-					{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
-				}}},
-			}
-			cursor, err := transactionCollection.Aggregate(ctx, aggregateQuery)
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-			defer cursor.Close(ctx)
-
-			type Result struct {
-				Answer string `bson:"_id" json:"answer"`
-				Count  int    `bson:"count"`
-			}
-
-			var results []Result
-
-			if err := cursor.All(ctx, &results); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+	return response, nil
+}
 
-			optionCountMap := make(map[string]int)
-			for _, option := range options {
-				optionCountMap[option] = 0
-			}
+func summarizeForm(ctx context.Context, post models.Post, withRespondents bool) (interface{}, error) {
+	var answers []models.AForm
+	cursor, err := transactionCollection.Find(ctx, submittedAnswers(post.ID))
+	if err != nil {
+		return nil, err
+	}
 
-			totalVotes := 0
-			for _, result := range results {
-				if _, exists := optionCountMap[result.Answer]; exists {
-					optionCountMap[result.Answer] = result.Count
-					totalVotes += result.Count
-				}
-			}
+	defer cursor.Close(ctx)
 
-			mappedResults := make([]struct {
-				Option string `json:"option"`
-				Count  int    `json:"count"`
-			}, 0, len(options))
-
-			for _, option := range options {
-				mappedResults = append(mappedResults, struct {
-					Option string `json:"option"`
-					Count  int    `json:"count"`
-				}{
-					Option: option,
-					Count:  optionCountMap[option],
-				})
-			}
+	if err := cursor.All(ctx, &answers); err != nil {
+		return nil, err
+	}
 
-			response := struct {
-				TotalVotes int `json:"totalVotes"`
-				Results    []struct {
-					Option string `json:"option"`
-					Count  int    `json:"count"`
-				} `json:"results"`
-			}{
-				TotalVotes: totalVotes,
-				Results:    mappedResults,
-			}
+	// Answers to questions that were edited since are not counted for the new ones
+	answers, stale, err := answersToCurrentQuestions(ctx, post, answers)
+	if err != nil {
+		return nil, err
+	}
 
-			c.JSON(http.StatusOK, gin.H{"success": true, "data": response})
-		case "form":
-			var answers []models.AForm
-			query := bson.M{"postID": postID}
-			cursor, err := transactionCollection.Find(ctx, query)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+	// Transform the data to the desired structure
+	resultMap := make(map[int]map[string][]map[string]interface{})
+	for _, answer := range answers {
+		for _, question := range answer.AnswerList {
+			if _, ok := resultMap[question.QuestionIndex]; !ok {
+				resultMap[question.QuestionIndex] = make(map[string][]map[string]interface{})
 			}
-
-			defer cursor.Close(ctx)
-
-			if err := cursor.All(ctx, &answers); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+			if _, ok := resultMap[question.QuestionIndex][question.InputType]; !ok {
+				resultMap[question.QuestionIndex][question.InputType] = []map[string]interface{}{}
 			}
-
-			// Transform the data to the desired structure
-			resultMap := make(map[int]map[string][]map[string]interface{})
-			for _, answer := range answers {
-				for _, question := range answer.AnswerList {
-					if _, ok := resultMap[question.QuestionIndex]; !ok {
-						resultMap[question.QuestionIndex] = make(map[string][]map[string]interface{})
-					}
-					if _, ok := resultMap[question.QuestionIndex][question.InputType]; !ok {
-						resultMap[question.QuestionIndex][question.InputType] = []map[string]interface{}{}
-					}
-					resultMap[question.QuestionIndex][question.InputType] = append(resultMap[question.QuestionIndex][question.InputType], map[string]interface{}{
-						"studentID": answer.StudentID,
-						"answer":    question.Answers,
-					})
-				}
+			studentAnswer := map[string]interface{}{
+				"answer": question.Answers,
 			}
-
-			// Convert the resultMap to the desired JSON structure
-			var results []map[string]interface{}
-			for questionIndex, typeMap := range resultMap {
-				for inputType, studentAnswers := range typeMap {
-					results = append(results, map[string]interface{}{
-						"questionIndex": questionIndex,
-						"type":          inputType,
-						"answers":       studentAnswers,
-					})
-				}
+			if withRespondents {
+				studentAnswer["studentID"] = answer.StudentID
 			}
+			resultMap[question.QuestionIndex][question.InputType] = append(resultMap[question.QuestionIndex][question.InputType], studentAnswer)
+		}
+	}
 
-			sort.Slice(results, func(i, j int) bool {
-				return results[i]["questionIndex"].(int) < results[j]["questionIndex"].(int)
+	// Convert the resultMap to the desired JSON structure
+	var results []map[string]interface{}
+	for questionIndex, typeMap := range resultMap {
+		for inputType, studentAnswers := range typeMap {
+			results = append(results, map[string]interface{}{
+				"questionIndex": questionIndex,
+				"type":          inputType,
+				"answers":       studentAnswers,
 			})
+		}
+	}
 
-			var question []QuestionForm
-			for i, fq := range post.FormQuestions {
-				question = append(question, QuestionForm{
-					QuestionIndex: i,
-					Question:      fq.Question,
-				})
-			}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i]["questionIndex"].(int) < results[j]["questionIndex"].(int)
+	})
 
-			response := map[string]interface{}{
-				"postID":       postID,
-				"formQuestion": question,
-				"results":      results,
-			}
+	var question []QuestionForm
+	for i, fq := range post.FormQuestions {
+		question = append(question, QuestionForm{
+			QuestionIndex: i,
+			Question:      fq.Question,
+		})
+	}
 
-			c.JSON(http.StatusOK, gin.H{"success": true, "data": response})
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown post kind"})
-			return
+	rate, err := formResponseRate(ctx, post, answers)
+	if err != nil {
+		return nil, err
+	}
 
-		}
+	response := map[string]interface{}{
+		"postID":       post.ID,
+		"formQuestion": question,
+		"results":      results,
+		"statistics":   formStatistics(post, answers),
+		"responseRate": rate,
+		"staleAnswers": stale,
 	}
+
+	return response, nil
 }
 
 func DeleteAllAnswers(postID primitive.ObjectID) error {
@@ -384,5 +636,21 @@ func DeleteAllAnswers(postID primitive.ObjectID) error {
 		log.Println("Error deleting transaction:", err)
 		return err
 	}
+
+	_, err = answerHistoryCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		log.Println("Error deleting answer history:", err)
+		return err
+	}
+
+	if err := deleteAnonymousVotes(ctx, postID); err != nil {
+		log.Println("Error deleting ballots:", err)
+		return err
+	}
+
+	if err := deleteUploads(ctx, postID); err != nil {
+		log.Println("Error deleting uploads:", err)
+		return err
+	}
 	return nil
 }
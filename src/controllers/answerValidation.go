@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of form questions, derived from their input type
+const (
+	questionSingleChoice = "single"
+	questionMultiChoice  = "multi"
+	questionNumeric      = "numeric"
+	questionText         = "text"
+	questionFile         = "file"
+)
+
+func questionKind(question models.FormQuestion) string {
+	switch question.InputType {
+	case models.InputRadio, models.InputDropdown:
+		return questionSingleChoice
+	case models.InputCheckbox:
+		return questionMultiChoice
+	case models.InputNumber, models.InputRating:
+		return questionNumeric
+	case models.InputText, models.InputTextArea:
+		return questionText
+	case models.InputFile:
+		return questionFile
+	}
+	if len(question.Options) > 0 {
+		if maxSelections(question) == 1 {
+			return questionSingleChoice
+		}
+		return questionMultiChoice
+	}
+	return questionText
+}
+
+// maxSelections returns the selection limit of a choice question, 0 if unlimited
+func maxSelections(question models.FormQuestion) int {
+	if question.InputType == models.InputRadio || question.InputType == models.InputDropdown {
+		return 1
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(question.MaxSel))
+	if err != nil || limit <= 0 {
+		return 0
+	}
+	return limit
+}
+
+// validateVoteAnswer checks that every question of the ballot is answered
+// with options of that question, following its voting method
+func validateVoteAnswer(post models.Post, vote models.AVote) []models.AnswerError {
+	var errs []models.AnswerError
+	questions := ballotQuestions(post)
+	seen := make(map[int]bool)
+
+	for _, choice := range voteChoices(vote) {
+		index := choice.QuestionIndex
+		if index < 0 || index >= len(questions) {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrUnknownQuestion, Message: "question does not exist"})
+			continue
+		}
+		if seen[index] {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrDuplicateQuestion, Message: "question is answered more than once"})
+			continue
+		}
+		seen[index] = true
+
+		errs = append(errs, validateVoteChoice(index, questions[index], choice.Choices)...)
+	}
+
+	for index := range questions {
+		if !seen[index] {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrMissingAnswer, Message: "an option must be chosen"})
+		}
+	}
+
+	return errs
+}
+
+// validateVoteChoice applies the rules of the voting method to the choices
+func validateVoteChoice(index int, question models.VoteQuestion, choices []string) []models.AnswerError {
+	var errs []models.AnswerError
+	fail := func(code string, format string, args ...interface{}) {
+		errs = append(errs, models.AnswerError{QuestionIndex: index, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(choices) == 0 {
+		fail(models.AnswerErrMissingAnswer, "an option must be chosen")
+		return errs
+	}
+
+	chosen := make(map[string]bool)
+	for _, option := range choices {
+		if !containsString(question.Options, option) {
+			fail(models.AnswerErrInvalidOption, "%q is not an option of this vote", option)
+		} else if chosen[option] {
+			fail(models.AnswerErrDuplicateOption, "%q is chosen more than once", option)
+		}
+		chosen[option] = true
+	}
+
+	switch question.Method {
+	case models.VoteApproval:
+		if question.MaxSel > 0 && len(choices) > question.MaxSel {
+			fail(models.AnswerErrTooManySelections, "at most %d option(s) can be approved", question.MaxSel)
+		}
+	case models.VoteRanked:
+		// Partial rankings are allowed, unranked options are never preferred
+	default:
+		if len(choices) > 1 {
+			fail(models.AnswerErrTooManySelections, "only one option can be chosen")
+		}
+	}
+
+	return errs
+}
+
+// validateFormAnswer checks every answered question of a form submission
+// against the question it claims to answer. Questions hidden by the form
+// rules are skipped, required questions that are shown must be answered.
+func validateFormAnswer(post models.Post, form models.AForm) []models.AnswerError {
+	var errs []models.AnswerError
+	seen := make(map[int]bool)
+	answers := formAnswerMap(form)
+	visible := visibleQuestions(post, answers)
+
+	for _, answer := range form.AnswerList {
+		index := answer.QuestionIndex
+		if index < 0 || index >= len(post.FormQuestions) {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrUnknownQuestion, Message: "question does not exist"})
+			continue
+		}
+		if seen[index] {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrDuplicateQuestion, Message: "question is answered more than once"})
+			continue
+		}
+		seen[index] = true
+		if !visible[index] {
+			continue
+		}
+
+		question := post.FormQuestions[index]
+		if answer.InputType != question.InputType {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrWrongInputType, Message: fmt.Sprintf("expected input type %q", question.InputType)})
+			continue
+		}
+
+		errs = append(errs, validateQuestionAnswer(index, question, answer.Answers)...)
+	}
+
+	for index, question := range post.FormQuestions {
+		if visible[index] && question.Required && !hasAnswer(answers[index]) {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrMissingAnswer, Message: "this question is required"})
+		}
+	}
+
+	return errs
+}
+
+// validateQuestionAnswer applies the rules of the question kind to the answers
+func validateQuestionAnswer(index int, question models.FormQuestion, answers []string) []models.AnswerError {
+	var errs []models.AnswerError
+	fail := func(code string, format string, args ...interface{}) {
+		errs = append(errs, models.AnswerError{QuestionIndex: index, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch questionKind(question) {
+	case questionSingleChoice, questionMultiChoice:
+		chosen := make(map[string]bool)
+		for _, option := range answers {
+			if !containsString(question.Options, option) {
+				fail(models.AnswerErrInvalidOption, "%q is not an option of this question", option)
+			} else if chosen[option] {
+				fail(models.AnswerErrDuplicateOption, "%q is selected more than once", option)
+			}
+			chosen[option] = true
+		}
+		limit := maxSelections(question)
+		if questionKind(question) == questionSingleChoice {
+			limit = 1
+		}
+		if limit > 0 && len(answers) > limit {
+			fail(models.AnswerErrTooManySelections, "at most %d option(s) can be selected", limit)
+		}
+		if len(answers) > 0 && question.Min != nil && float64(len(answers)) < *question.Min {
+			fail(models.AnswerErrTooFewSelections, "at least %v option(s) must be selected", *question.Min)
+		}
+		if question.Max != nil && float64(len(answers)) > *question.Max {
+			fail(models.AnswerErrTooManySelections, "at most %v option(s) can be selected", *question.Max)
+		}
+	case questionNumeric:
+		if len(answers) > 1 {
+			fail(models.AnswerErrTooManyAnswers, "only one value can be given")
+		}
+		for _, value := range answers {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+				fail(models.AnswerErrNotANumber, "%q is not a number", value)
+			} else if (question.Min != nil && number < *question.Min) || (question.Max != nil && number > *question.Max) {
+				fail(models.AnswerErrOutOfRange, "%v is outside the allowed range", number)
+			}
+		}
+	case questionText:
+		if len(answers) > 1 {
+			fail(models.AnswerErrTooManyAnswers, "only one answer can be given")
+		}
+		for _, value := range answers {
+			length := float64(utf8.RuneCountInString(strings.TrimSpace(value)))
+			if length > 0 && question.Min != nil && length < *question.Min {
+				fail(models.AnswerErrTooShort, "at least %v characters are needed", *question.Min)
+			}
+			if question.Max != nil && length > *question.Max {
+				fail(models.AnswerErrTooLong, "at most %v characters are allowed", *question.Max)
+			}
+		}
+	case questionFile:
+		seen := make(map[string]bool)
+		for _, id := range answers {
+			if _, err := primitive.ObjectIDFromHex(id); err != nil {
+				fail(models.AnswerErrInvalidFile, "%q is not an upload", id)
+			} else if seen[id] {
+				fail(models.AnswerErrDuplicateOption, "%q is attached more than once", id)
+			}
+			seen[id] = true
+		}
+		if limit := maxFiles(question); len(answers) > limit {
+			fail(models.AnswerErrTooManyAnswers, "at most %d file(s) can be attached", limit)
+		}
+		if len(answers) > 0 && question.Min != nil && float64(len(answers)) < *question.Min {
+			fail(models.AnswerErrTooFewSelections, "at least %v file(s) must be attached", *question.Min)
+		}
+	}
+
+	return errs
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+// errorCodes lists the codes of the errors in order
+func errorCodes(errs []models.AnswerError) []string {
+	codes := []string{}
+	for _, err := range errs {
+		codes = append(codes, err.Code)
+	}
+	return codes
+}
+
+func TestQuestionKind(t *testing.T) {
+	tests := []struct {
+		question models.FormQuestion
+		want     string
+	}{
+		{models.FormQuestion{InputType: models.InputRadio}, questionSingleChoice},
+		{models.FormQuestion{InputType: models.InputDropdown}, questionSingleChoice},
+		{models.FormQuestion{InputType: models.InputCheckbox}, questionMultiChoice},
+		{models.FormQuestion{InputType: models.InputNumber}, questionNumeric},
+		{models.FormQuestion{InputType: models.InputRating}, questionNumeric},
+		{models.FormQuestion{InputType: models.InputTextArea}, questionText},
+		{models.FormQuestion{InputType: models.InputFile}, questionFile},
+		{models.FormQuestion{InputType: "choice", Options: []string{"A"}, MaxSel: "1"}, questionSingleChoice},
+		{models.FormQuestion{InputType: "choice", Options: []string{"A", "B"}}, questionMultiChoice},
+		{models.FormQuestion{InputType: "unknown"}, questionText},
+	}
+
+	for _, tt := range tests {
+		if got := questionKind(tt.question); got != tt.want {
+			t.Errorf("questionKind(%+v) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestValidateQuestionAnswer(t *testing.T) {
+	two, three, ten := 2.0, 3.0, 10.0
+
+	tests := []struct {
+		name     string
+		question models.FormQuestion
+		answers  []string
+		want     []string
+	}{
+		{"radio option", models.FormQuestion{InputType: models.InputRadio, Options: []string{"A", "B"}}, []string{"A"}, []string{}},
+		{"radio unknown option", models.FormQuestion{InputType: models.InputRadio, Options: []string{"A", "B"}}, []string{"C"}, []string{models.AnswerErrInvalidOption}},
+		{"radio two options", models.FormQuestion{InputType: models.InputRadio, Options: []string{"A", "B"}}, []string{"A", "B"}, []string{models.AnswerErrTooManySelections}},
+		{"checkbox repeated option", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B"}}, []string{"A", "A"}, []string{models.AnswerErrDuplicateOption}},
+		{"checkbox over maxSel", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}, MaxSel: "2"}, []string{"A", "B", "C"}, []string{models.AnswerErrTooManySelections}},
+		{"checkbox under min", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}, Min: &two}, []string{"A"}, []string{models.AnswerErrTooFewSelections}},
+		{"number in range", models.FormQuestion{InputType: models.InputNumber, Min: &two, Max: &ten}, []string{" 4.5 "}, []string{}},
+		{"number out of range", models.FormQuestion{InputType: models.InputNumber, Min: &two, Max: &ten}, []string{"11"}, []string{models.AnswerErrOutOfRange}},
+		{"number not a number", models.FormQuestion{InputType: models.InputNumber}, []string{"four"}, []string{models.AnswerErrNotANumber}},
+		{"number NaN", models.FormQuestion{InputType: models.InputNumber}, []string{"NaN"}, []string{models.AnswerErrNotANumber}},
+		{"number infinite", models.FormQuestion{InputType: models.InputRating}, []string{"-Inf"}, []string{models.AnswerErrNotANumber}},
+		{"number twice", models.FormQuestion{InputType: models.InputNumber}, []string{"1", "2"}, []string{models.AnswerErrTooManyAnswers}},
+		{"text too short", models.FormQuestion{InputType: models.InputText, Min: &three}, []string{"ab"}, []string{models.AnswerErrTooShort}},
+		{"text counts characters", models.FormQuestion{InputType: models.InputText, Max: &three}, []string{"สวัส"}, []string{models.AnswerErrTooLong}},
+		{"text empty skips min", models.FormQuestion{InputType: models.InputText, Min: &three}, []string{""}, []string{}},
+		{"file upload", models.FormQuestion{InputType: models.InputFile}, []string{"64b7f0c2a1b2c3d4e5f60718"}, []string{}},
+		{"file not an upload", models.FormQuestion{InputType: models.InputFile}, []string{"report.pdf"}, []string{models.AnswerErrInvalidFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorCodes(validateQuestionAnswer(0, tt.question, tt.answers))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateQuestionAnswer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFormAnswer(t *testing.T) {
+	post := models.Post{FormQuestions: []models.FormQuestion{
+		{InputType: models.InputRadio, Options: []string{"Yes", "No"}, Required: true},
+		{InputType: models.InputText, Required: true, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "No"}}},
+	}}
+
+	tests := []struct {
+		name    string
+		answers []models.AQuestion
+		want    []string
+	}{
+		{"complete", []models.AQuestion{{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"Yes"}}}, []string{}},
+		{"required question missing", nil, []string{models.AnswerErrMissingAnswer}},
+		{"shown follow-up missing", []models.AQuestion{{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"No"}}}, []string{models.AnswerErrMissingAnswer}},
+		{"unknown question", []models.AQuestion{
+			{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"Yes"}},
+			{QuestionIndex: 4, InputType: models.InputText, Answers: []string{"x"}},
+		}, []string{models.AnswerErrUnknownQuestion}},
+		{"answered twice", []models.AQuestion{
+			{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"Yes"}},
+			{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"No"}},
+		}, []string{models.AnswerErrDuplicateQuestion}},
+		{"wrong input type", []models.AQuestion{{QuestionIndex: 0, InputType: models.InputText, Answers: []string{"Yes"}}}, []string{models.AnswerErrWrongInputType}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorCodes(validateFormAnswer(post, models.AForm{AnswerList: tt.answers}))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateFormAnswer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVoteAnswer(t *testing.T) {
+	post := models.Post{Ballot: []models.VoteQuestion{
+		{Options: []string{"A", "B"}},
+		{Options: []string{"X", "Y", "Z"}, Method: models.VoteApproval, MaxSel: 2},
+		{Options: []string{"P", "Q", "R"}, Method: models.VoteRanked},
+	}}
+	full := func(choices ...models.VoteChoice) models.AVote {
+		answers := []models.VoteChoice{
+			{QuestionIndex: 0, Choices: []string{"A"}},
+			{QuestionIndex: 1, Choices: []string{"X"}},
+			{QuestionIndex: 2, Choices: []string{"Q", "P"}},
+		}
+		for _, choice := range choices {
+			answers[choice.QuestionIndex] = choice
+		}
+		return models.AVote{Answers: answers}
+	}
+
+	tests := []struct {
+		name string
+		vote models.AVote
+		want []string
+	}{
+		{"valid ballot", full(), []string{}},
+		{"single with two options", full(models.VoteChoice{QuestionIndex: 0, Choices: []string{"A", "B"}}), []string{models.AnswerErrTooManySelections}},
+		{"approval over maxSel", full(models.VoteChoice{QuestionIndex: 1, Choices: []string{"X", "Y", "Z"}}), []string{models.AnswerErrTooManySelections}},
+		{"ranked repeated option", full(models.VoteChoice{QuestionIndex: 2, Choices: []string{"P", "P"}}), []string{models.AnswerErrDuplicateOption}},
+		{"unknown option", full(models.VoteChoice{QuestionIndex: 0, Choices: []string{"C"}}), []string{models.AnswerErrInvalidOption}},
+		{"empty choice", full(models.VoteChoice{QuestionIndex: 0}), []string{models.AnswerErrMissingAnswer}},
+		{"missing questions", models.AVote{Answer: "A"}, []string{models.AnswerErrMissingAnswer, models.AnswerErrMissingAnswer}},
+		{"unknown question", models.AVote{Answers: append(full().Answers, models.VoteChoice{QuestionIndex: 3, Choices: []string{"A"}})}, []string{models.AnswerErrUnknownQuestion}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorCodes(validateVoteAnswer(post, tt.vote))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateVoteAnswer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
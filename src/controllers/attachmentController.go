@@ -0,0 +1,611 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+	"github.com/encall/cpeevent-backend/src/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var attachmentCollection *mongo.Collection = database.OpenCollection(database.Client, "attachments")
+
+// Download links of attachments name the file with this prefix, so they
+// cannot be used for uploads and the other way round
+const attachmentLinkPrefix = "attachment/"
+
+// Longest side of the preview of image attachments
+const attachmentPreviewSide = 480
+
+// maxAttachmentSize applies to every file attached to a post
+var maxAttachmentSize = attachmentSizeLimit()
+
+// attachmentQuota is how much the attachments of one event may take together
+var attachmentQuota = attachmentQuotaLimit()
+
+// attachmentTypes are the files posts accept: documents, slides and images
+var attachmentTypes = []string{
+	"application/pdf",
+	"image/*",
+	"text/plain",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.oasis.opendocument.text",
+	"application/vnd.oasis.opendocument.spreadsheet",
+	"application/vnd.oasis.opendocument.presentation",
+}
+
+// Office documents are sniffed as the container they are stored in, a zip
+// archive or an OLE compound file. The extension tells what they hold.
+var (
+	zipDocumentTypes = map[string]string{
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		".odt":  "application/vnd.oasis.opendocument.text",
+		".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		".odp":  "application/vnd.oasis.opendocument.presentation",
+	}
+	oleDocumentTypes = map[string]string{
+		".doc": "application/msword",
+		".xls": "application/vnd.ms-excel",
+		".ppt": "application/vnd.ms-powerpoint",
+	}
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+func attachmentSizeLimit() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_SIZE_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = 25
+	}
+	return int64(megabytes) << 20
+}
+
+func attachmentQuotaLimit() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("ATTACHMENT_QUOTA_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = 200
+	}
+	return int64(megabytes) << 20
+}
+
+// attachmentContentType sniffs the type of a file from its first bytes
+func attachmentContentType(head []byte, fileName string) string {
+	contentType := http.DetectContentType(head)
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if documentType, ok := zipDocumentTypes[ext]; ok && contentType == "application/zip" {
+		return documentType
+	}
+	if documentType, ok := oleDocumentTypes[ext]; ok && bytes.HasPrefix(head, oleMagic) {
+		return documentType
+	}
+	return contentType
+}
+
+// countAttachmentUsage adds up the files attached to the posts of an event
+// and their previews
+func countAttachmentUsage(ctx context.Context, eventID primitive.ObjectID) (int64, error) {
+	cursor, err := attachmentCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"eventID": eventID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "used": bson.M{"$sum": bson.M{"$add": bson.A{"$size", bson.M{"$ifNull": bson.A{"$previewSize", 0}}}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Used int64 `bson:"used"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return totals[0].Used, nil
+}
+
+// attachmentUsage is the space the attachments of an event take, as kept in
+// its counter. Events without one are counted first.
+func attachmentUsage(ctx context.Context, eventID primitive.ObjectID) (int64, error) {
+	var event models.Event
+	opts := options.FindOne().SetProjection(bson.M{"attachmentUsage": 1})
+	if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}, opts).Decode(&event); err != nil {
+		return 0, err
+	}
+	if event.AttachmentUsage != nil {
+		return *event.AttachmentUsage, nil
+	}
+
+	used, err := countAttachmentUsage(ctx, eventID)
+	if err != nil {
+		return 0, err
+	}
+	filter := bson.M{"_id": eventID, "attachmentUsage": bson.M{"$exists": false}}
+	result, err := eventCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"attachmentUsage": used}})
+	if err != nil {
+		return 0, err
+	}
+	if result.ModifiedCount == 0 {
+		return attachmentUsage(ctx, eventID) // Another request started the counter meanwhile
+	}
+	return used, nil
+}
+
+// reserveAttachmentSpace takes space in the quota of an event before a file
+// is stored. The check and the reservation are one update, so uploads at the
+// same time cannot go over the quota together. It returns the usage after
+// the reservation, or the current one when the file does not fit.
+func reserveAttachmentSpace(ctx context.Context, eventID primitive.ObjectID, size int64) (int64, bool, error) {
+	if _, err := attachmentUsage(ctx, eventID); err != nil {
+		return 0, false, err
+	}
+
+	var event models.Event
+	filter := bson.M{"_id": eventID, "attachmentUsage": bson.M{"$lte": attachmentQuota - size}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"attachmentUsage": 1})
+	err := eventCollection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attachmentUsage": size}}, opts).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		used, err := attachmentUsage(ctx, eventID)
+		return used, false, err
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return *event.AttachmentUsage, true, nil
+}
+
+// releaseAttachmentSpace gives back space reserved in the quota of an event
+func releaseAttachmentSpace(ctx context.Context, eventID primitive.ObjectID, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	filter := bson.M{"_id": eventID, "attachmentUsage": bson.M{"$exists": true}}
+	_, err := eventCollection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"attachmentUsage": -size}})
+	return err
+}
+
+// loadReadablePost fetches a post the user may read. The flag tells whether
+// the user organizes it.
+func loadReadablePost(c *gin.Context, ctx context.Context, postID primitive.ObjectID) (models.Post, models.Event, bool, bool) {
+	var post models.Post
+
+	userID, exists := c.Get("studentid")
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		return post, models.Event{}, false, false
+	}
+	access, _ := c.Get("access")
+
+	if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return post, models.Event{}, false, false
+	}
+	event, err := findPostEvent(ctx, postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return post, event, false, false
+	}
+
+	member := getMembership(event, userID)
+	if access.(int) < 3 && !readsPost(post, member) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return post, event, false, false
+	}
+	return post, event, access.(int) >= 3 || member.IsOrganizer(), true
+}
+
+// savePreview stores a downscaled copy of an image attachment. Images that
+// cannot be decoded, or whose preview does not fit in the quota, are kept
+// without one.
+func savePreview(ctx context.Context, attachment *models.Attachment, data []byte) error {
+	img, format, err := helper.DecodeImage(data)
+	if err != nil {
+		return nil
+	}
+	preview, previewType, err := helper.EncodeImage(helper.Thumbnail(img, attachmentPreviewSide), format)
+	if err != nil {
+		return nil
+	}
+
+	_, ok, err := reserveAttachmentSpace(ctx, attachment.EventID, int64(len(preview)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	attachment.PreviewKey = attachment.Key + "-preview"
+	if err := storage.Backend.Save(ctx, attachment.PreviewKey, bytes.NewReader(preview)); err != nil {
+		releaseAttachmentSpace(ctx, attachment.EventID, int64(len(preview)))
+		return err
+	}
+	attachment.HasPreview = true
+	attachment.PreviewType = previewType
+	attachment.PreviewSize = int64(len(preview))
+	attachment.Width = img.Bounds().Dx()
+	attachment.Height = img.Bounds().Dy()
+	return nil
+}
+
+// removeAttachmentFiles deletes the stored files of an attachment
+func removeAttachmentFiles(ctx context.Context, attachment models.Attachment) error {
+	if err := storage.Backend.Delete(ctx, attachment.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if attachment.PreviewKey != "" {
+		if err := storage.Backend.Delete(ctx, attachment.PreviewKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadAttachment attaches a file to a post, within the quota of its event
+func UploadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		_, event, ok := loadOrganizedPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing, the file itself is checked below
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+		header, err := c.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxFileSize": maxAttachmentSize})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if header.Size > maxAttachmentSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxFileSize": maxAttachmentSize})
+			return
+		}
+
+		used, ok, err := reserveAttachmentSpace(ctx, event.ID, header.Size)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The attachments of the event are over quota", "used": used, "quota": attachmentQuota})
+			return
+		}
+		// The space is given back unless the attachment is stored
+		reserved, stored := header.Size, false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := releaseAttachmentSpace(ctx, event.ID, reserved); err != nil {
+				log.Printf("Error releasing attachment space of event %s: %v", event.ID.Hex(), err)
+			}
+		}()
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+
+		// The type is taken from the content, the name and header can be anything
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading file"})
+			return
+		}
+		head = head[:n]
+		fileName := filepath.Base(header.Filename)
+		contentType := attachmentContentType(head, fileName)
+		if !matchesContentType(attachmentTypes, contentType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type is not allowed", "contentType": contentType})
+			return
+		}
+
+		attachment := models.Attachment{
+			ID:          primitive.NewObjectID(),
+			PostID:      postID,
+			EventID:     event.ID,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        header.Size,
+			UploadedBy:  userID.(string),
+			UploadedAt:  time.Now().UTC(),
+		}
+		attachment.Key = "attachments/" + postID.Hex() + "/" + attachment.ID.Hex()
+
+		var content io.Reader = io.MultiReader(bytes.NewReader(head), file)
+		if strings.HasPrefix(contentType, "image/") {
+			// Images are read whole for their preview
+			data, err := io.ReadAll(io.LimitReader(content, maxAttachmentSize))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading file"})
+				return
+			}
+			if err := savePreview(ctx, &attachment, data); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing file"})
+				return
+			}
+			reserved += attachment.PreviewSize
+			content = bytes.NewReader(data)
+		}
+
+		if err := storage.Backend.Save(ctx, attachment.Key, content); err != nil {
+			removeAttachmentFiles(ctx, attachment)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing file"})
+			return
+		}
+		if _, err := attachmentCollection.InsertOne(ctx, attachment); err != nil {
+			removeAttachmentFiles(ctx, attachment)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		stored = true
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": attachment, "usage": gin.H{
+			"used":  used + attachment.PreviewSize,
+			"quota": attachmentQuota,
+		}})
+	}
+}
+
+// GetAttachments lists the files attached to a post, for whoever can read it
+func GetAttachments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		_, event, organizer, ok := loadReadablePost(c, ctx, postID)
+		if !ok {
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "uploadedAt", Value: 1}, {Key: "_id", Value: 1}})
+		cursor, err := attachmentCollection.Find(ctx, bson.M{"postID": postID}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		attachments := []models.Attachment{}
+		if err := cursor.All(ctx, &attachments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := gin.H{"success": true, "data": attachments}
+		// The quota is only of interest to those who attach files
+		if organizer {
+			used, err := attachmentUsage(ctx, event.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			response["usage"] = gin.H{"used": used, "quota": attachmentQuota}
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetAttachmentLink returns short-lived download links to an attachment and
+// its preview, for whoever can read the post
+func GetAttachmentLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		attachmentID, err := primitive.ObjectIDFromHex(c.Param("attachmentID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachmentID format"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := attachmentCollection.FindOne(ctx, bson.M{"_id": attachmentID}).Decode(&attachment); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		if _, _, _, ok := loadReadablePost(c, ctx, attachment.PostID); !ok {
+			return
+		}
+
+		token, expiresAt, err := helper.GenerateFileToken(attachmentLinkPrefix + attachment.ID.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating download link"})
+			return
+		}
+		data := gin.H{
+			"file":      attachment,
+			"url":       "/api/v1/attachments/" + token,
+			"expiresAt": expiresAt,
+		}
+		if attachment.HasPreview {
+			previewToken, _, err := helper.GenerateFileToken(attachmentLinkPrefix + attachment.ID.Hex() + "/preview")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating download link"})
+				return
+			}
+			data["previewUrl"] = "/api/v1/attachments/" + previewToken
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+	}
+}
+
+// DownloadAttachment serves an attachment, or its preview, to whoever holds
+// a valid download link
+func DownloadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		claims, err := helper.ValidateFileToken(c.Param("token"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired download link"})
+			return
+		}
+		name, ok := strings.CutPrefix(claims.FileID, attachmentLinkPrefix)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+		hexID, preview := strings.CutSuffix(name, "/preview")
+		attachmentID, err := primitive.ObjectIDFromHex(hexID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := attachmentCollection.FindOne(ctx, bson.M{"_id": attachmentID}).Decode(&attachment); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+
+		key, size, contentType := attachment.Key, attachment.Size, attachment.ContentType
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+		if preview {
+			if !attachment.HasPreview {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no preview"})
+				return
+			}
+			key, size, contentType = attachment.PreviewKey, attachment.PreviewSize, attachment.PreviewType
+			disposition = "inline"
+		}
+
+		reader, err := storage.Backend.Open(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading file"})
+			return
+		}
+		defer reader.Close()
+
+		c.DataFromReader(http.StatusOK, size, contentType, reader, map[string]string{
+			"Content-Disposition":    disposition,
+			"Cache-Control":          "private, no-store",
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+// DeleteAttachment removes a file from a post, freeing its space in the quota
+func DeleteAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		attachmentID, err := primitive.ObjectIDFromHex(c.Param("attachmentID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachmentID format"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := attachmentCollection.FindOne(ctx, bson.M{"_id": attachmentID}).Decode(&attachment); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		if _, _, ok := loadOrganizedPost(c, ctx, attachment.PostID); !ok {
+			return
+		}
+
+		if err := removeAttachmentFiles(ctx, attachment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting file"})
+			return
+		}
+		if err := deleteAttachment(ctx, attachment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "attachment deleted"})
+	}
+}
+
+// deleteAttachments removes the files attached to a post
+func deleteAttachments(ctx context.Context, postID primitive.ObjectID) error {
+	cursor, err := attachmentCollection.Find(ctx, bson.M{"postID": postID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var attachments []models.Attachment
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		if err := removeAttachmentFiles(ctx, attachment); err != nil {
+			log.Printf("Error deleting attachment %s: %v", attachment.Key, err)
+			return err
+		}
+		if err := deleteAttachment(ctx, attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAttachment removes the record of an attachment and frees its space in
+// the quota, once even when it is deleted twice at the same time
+func deleteAttachment(ctx context.Context, attachment models.Attachment) error {
+	result, err := attachmentCollection.DeleteOne(ctx, bson.M{"_id": attachment.ID})
+	if err != nil || result.DeletedCount == 0 {
+		return err
+	}
+	return releaseAttachmentSpace(ctx, attachment.EventID, attachment.Size+attachment.PreviewSize)
+}
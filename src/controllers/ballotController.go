@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	mrand "math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Anonymous votes are split over two collections: the participation ledger
+// says who voted, the ballot box what was voted, and nothing links the two.
+var participationCollection *mongo.Collection = database.OpenCollection(database.Client, "participation")
+var ballotCollection *mongo.Collection = database.OpenCollection(database.Client, "ballots")
+
+// pendingBallotCollection holds the ballots of anonymous votes until they are
+// mixed into the ballot box. Written one by one right after the participation
+// record, the order and time of the writes would tie every ballot to its
+// voter. Pending ballots are kept in the database like ballots, without any
+// student, so they survive restarts and any server of the deployment can
+// release them.
+var pendingBallotCollection *mongo.Collection = database.OpenCollection(database.Client, "pendingBallots")
+
+// ballotBatchSize is how many ballots of a post are held back before they are
+// moved to the ballot box together, in a random order
+const ballotBatchSize = 5
+
+// ballotClaimTimeout is how long a server may take to move the batch it
+// claimed before another one takes it over
+const ballotClaimTimeout = 5 * time.Minute
+
+// pendingBallot is a ballot waiting to be mixed, with the claim of the server
+// moving it
+type pendingBallot struct {
+	models.Ballot `bson:",inline"`
+	Claim         string     `bson:"claim,omitempty"`
+	ClaimedAt     *time.Time `bson:"claimedAt,omitempty"`
+}
+
+// releaseBallots moves the pending ballots of a post to the ballot box in a
+// random order. Unless all is set, nothing moves before a full batch waits.
+func releaseBallots(ctx context.Context, postID primitive.ObjectID, all bool) error {
+	claim, err := randomHex(12)
+	if err != nil {
+		return err
+	}
+
+	// Each ballot is claimed by one server only. Claims of servers that
+	// stopped halfway are taken over after a while.
+	now := time.Now().UTC()
+	filter := bson.M{"postID": postID, "$or": bson.A{
+		bson.M{"claim": bson.M{"$exists": false}},
+		bson.M{"claimedAt": bson.M{"$lt": now.Add(-ballotClaimTimeout)}},
+	}}
+	if _, err := pendingBallotCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"claim": claim, "claimedAt": now}}); err != nil {
+		return err
+	}
+
+	cursor, err := pendingBallotCollection.Find(ctx, bson.M{"claim": claim})
+	if err != nil {
+		return err
+	}
+	var batch []pendingBallot
+	if err := cursor.All(ctx, &batch); err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	if !all && len(batch) < ballotBatchSize {
+		_, err := pendingBallotCollection.UpdateMany(ctx, bson.M{"claim": claim}, bson.M{"$unset": bson.M{"claim": "", "claimedAt": ""}})
+		return err
+	}
+
+	mrand.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+	docs := make([]interface{}, len(batch))
+	for i, pending := range batch {
+		docs[i] = pending.Ballot
+	}
+	// A batch taken over may already be partly in the ballot box
+	_, err = ballotCollection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeys(err) {
+		return err
+	}
+	if _, err := pendingBallotCollection.DeleteMany(ctx, bson.M{"claim": claim}); err != nil {
+		return err
+	}
+	notifyAnswerChange(postID)
+	return nil
+}
+
+// onlyDuplicateKeys reports whether every write of a bulk insert that failed
+// did so because the document was there already
+func onlyDuplicateKeys(err error) bool {
+	var bulk mongo.BulkWriteException
+	if !errors.As(err, &bulk) || bulk.WriteConcernError != nil || len(bulk.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulk.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+// countPendingBallots is the number of ballots of a post not in the ballot
+// box yet. Ballots being moved are counted once.
+func countPendingBallots(ctx context.Context, postID primitive.ObjectID) (int64, error) {
+	cursor, err := pendingBallotCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"postID": postID}}},
+		{{Key: "$lookup", Value: bson.M{"from": "ballots", "localField": "_id", "foreignField": "_id", "as": "written"}}},
+		{{Key: "$match", Value: bson.M{"written": bson.M{"$size": 0}}}},
+		{{Key: "$count", Value: "pending"}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		Pending int64 `bson:"pending"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return 0, err
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	return counts[0].Pending, nil
+}
+
+// FlushClosedBallots moves the ballots still held back for votes that have
+// closed, whatever the size of their batch
+func FlushClosedBallots() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	postIDs, err := pendingBallotCollection.Distinct(ctx, "postID", bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, id := range postIDs {
+		postID, ok := id.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		var post models.Post
+		err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post)
+		if err == mongo.ErrNoDocuments {
+			if _, err := pendingBallotCollection.DeleteMany(ctx, bson.M{"postID": postID}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !postClosed(post) {
+			continue
+		}
+		if err := releaseBallots(ctx, postID, true); err != nil {
+			log.Printf("Error writing ballots of post %s: %v", postID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// castAnonymousVote records the participation of the student and drops the
+// ballot with the pending ones. The voter gets a receipt to check their ballot was counted.
+func castAnonymousVote(c *gin.Context, ctx context.Context, post models.Post, vote models.AVote) {
+	event, err := findPostEvent(ctx, post.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !getMembership(event, vote.StudentID).IsMember() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only members of the event can vote"})
+		return
+	}
+
+	receipt, err := randomHex(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating receipt"})
+		return
+	}
+	ballotID, err := randomHex(12)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating ballot"})
+		return
+	}
+
+	participation := models.Participation{
+		ID:        primitive.NewObjectID(),
+		PostID:    post.ID,
+		StudentID: vote.StudentID,
+		VotedAt:   time.Now().UTC(),
+	}
+
+	// The unique index on the ledger guarantees one vote per student
+	_, err = participationCollection.InsertOne(ctx, participation)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already voted"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ballot := models.Ballot{
+		ID:          ballotID,
+		PostID:      post.ID,
+		Answer:      vote.Answer,
+		Answers:     vote.Answers,
+		ReceiptHash: hashReceipt(receipt),
+	}
+	if _, err := pendingBallotCollection.InsertOne(ctx, ballot); err != nil {
+		// Without its ballot the vote did not happen, the student may try again
+		if _, delErr := participationCollection.DeleteOne(ctx, bson.M{"_id": participation.ID}); delErr != nil {
+			log.Printf("Error removing participation of post %s: %v", post.ID.Hex(), delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := releaseBallots(ctx, post.ID, false); err != nil {
+		// The ballot stays pending until the next vote or the close of the post
+		log.Printf("Error writing ballots of post %s: %v", post.ID.Hex(), err)
+	}
+
+	notifyAnswerChange(post.ID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"receipt": receipt}, "message": "answer submitted"})
+}
+
+// VerifyBallot lets a voter check that the ballot of their receipt is counted
+func VerifyBallot() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		receiptHash := hashReceipt(c.Param("receipt"))
+		filter := bson.M{"postID": postID, "receiptHash": receiptHash}
+
+		var ballot models.Ballot
+		err = ballotCollection.FindOne(ctx, filter).Decode(&ballot)
+		if err == mongo.ErrNoDocuments {
+			pending, err := pendingBallotCollection.CountDocuments(ctx, filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if pending > 0 {
+				c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"counted": false, "pending": true}})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "No ballot for this receipt"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"counted": true, "answer": ballot.Answer, "answers": ballot.Answers}})
+	}
+}
+
+// hasVotedAnonymously checks the participation ledger
+func hasVotedAnonymously(ctx context.Context, postID primitive.ObjectID, studentID string) (bool, error) {
+	count, err := participationCollection.CountDocuments(ctx, bson.M{"postID": postID, "studentID": studentID})
+	return count > 0, err
+}
+
+// voteAudit compares the ledger with the ballot box of an anonymous vote
+type voteAudit struct {
+	Voters     int64 `json:"voters"`
+	Ballots    int64 `json:"ballots"`
+	Pending    int64 `json:"pending"` // Waiting to be mixed, not counted yet
+	Consistent bool  `json:"consistent"`
+}
+
+func auditAnonymousVote(ctx context.Context, postID primitive.ObjectID) (*voteAudit, error) {
+	voters, err := participationCollection.CountDocuments(ctx, bson.M{"postID": postID})
+	if err != nil {
+		return nil, err
+	}
+	ballots, err := ballotCollection.CountDocuments(ctx, bson.M{"postID": postID})
+	if err != nil {
+		return nil, err
+	}
+	pending, err := countPendingBallots(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	return &voteAudit{Voters: voters, Ballots: ballots, Pending: pending, Consistent: voters == ballots+pending}, nil
+}
+
+// deleteAnonymousVotes empties the ledger and ballot box of a post
+func deleteAnonymousVotes(ctx context.Context, postID primitive.ObjectID) error {
+	if _, err := pendingBallotCollection.DeleteMany(ctx, bson.M{"postID": postID}); err != nil {
+		return err
+	}
+	if _, err := participationCollection.DeleteMany(ctx, bson.M{"postID": postID}); err != nil {
+		return err
+	}
+	_, err := ballotCollection.DeleteMany(ctx, bson.M{"postID": postID})
+	return err
+}
+
+func hashReceipt(receipt string) string {
+	sum := sha256.Sum256([]byte(receipt))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// calendarFeedPath is where personal feeds are served, relative to /api/v1
+const calendarFeedPath = "/calendar/feed/"
+
+// GetPublicCalendar serves every published event as an iCalendar feed
+func GetPublicCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"status": bson.M{"$nin": []string{models.EventStatusDraft, models.EventStatusArchived}}}
+		cursor, err := eventCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "startDate", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var events []models.Event
+		if err := cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		writeCalendar(c, "CPE Events", calendarEvents(events), nil)
+	}
+}
+
+// GetPersonalCalendar serves the events and post deadlines of one student.
+// The token in the URL is the only credential, so calendar apps can poll it.
+// Deadlines are VEVENTs by default, ?deadlines=todo emits VTODOs instead.
+func GetPersonalCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		if token == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+			return
+		}
+
+		var user struct {
+			StudentID string `bson:"studentID"`
+		}
+		projection := bson.M{"studentID": 1}
+		if err := userCollection.FindOne(ctx, bson.M{"calendarToken": token}, options.FindOne().SetProjection(projection)).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+			return
+		}
+
+		filter := bson.M{
+			"$or":    []bson.M{{"participants": user.StudentID}, {"staff.stdID": user.StudentID}},
+			"status": bson.M{"$ne": models.EventStatusDraft},
+		}
+		cursor, err := eventCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "startDate", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var events []models.Event
+		if err := cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries := calendarEvents(events)
+		var todos []helper.ICalTodo
+		asTodo := c.Query("deadlines") == "todo"
+
+		for _, event := range events {
+			member := getMembership(event, user.StudentID)
+			filter := visiblePostsFilter(event, member)
+			filter["endDate"] = bson.M{"$ne": nil}
+			if !member.IsOrganizer() {
+				filter["$and"] = livePostsFilter(postClock())["$and"]
+			}
+
+			postCursor, err := postCollection.Find(ctx, filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving posts"})
+				return
+			}
+			var posts []models.Post
+			err = postCursor.All(ctx, &posts)
+			postCursor.Close(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding posts"})
+				return
+			}
+
+			for _, post := range posts {
+				due, _ := studentDeadline(post, user.StudentID)
+				summary := event.EventName + ": " + post.Title
+				uid := post.ID.Hex() + "-deadline@cpeevo"
+				if asTodo {
+					todos = append(todos, helper.ICalTodo{UID: uid, Summary: summary, Description: post.Description, Due: due})
+				} else {
+					entries = append(entries, helper.ICalEvent{UID: uid, Summary: "Deadline - " + summary, Description: post.Description, Start: due, End: due})
+				}
+			}
+		}
+
+		writeCalendar(c, "My CPE Events", entries, todos)
+	}
+}
+
+// GetCalendarToken returns the personal feed of the user, if any
+func GetCalendarToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var user struct {
+			CalendarToken *string `bson:"calendarToken"`
+		}
+		projection := bson.M{"calendarToken": 1}
+		if err := userCollection.FindOne(ctx, bson.M{"studentID": userID}, options.FindOne().SetProjection(projection)).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if user.CalendarToken == nil || *user.CalendarToken == "" {
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": nil})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": calendarFeed(*user.CalendarToken)})
+	}
+}
+
+// RotateCalendarToken issues a new feed URL, invalidating the previous one
+func RotateCalendarToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating calendar token"})
+			return
+		}
+		token := hex.EncodeToString(buf)
+
+		_, err := userCollection.UpdateOne(ctx, bson.M{"studentID": userID}, bson.M{"$set": bson.M{"calendarToken": token}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": calendarFeed(token)})
+	}
+}
+
+// RevokeCalendarToken disables the personal feed
+func RevokeCalendarToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		_, err := userCollection.UpdateOne(ctx, bson.M{"studentID": userID}, bson.M{"$unset": bson.M{"calendarToken": ""}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Calendar feed revoked"})
+	}
+}
+
+func calendarFeed(token string) gin.H {
+	return gin.H{"token": token, "path": "/api/v1" + calendarFeedPath + token + ".ics"}
+}
+
+func calendarEvents(events []models.Event) []helper.ICalEvent {
+	entries := make([]helper.ICalEvent, 0, len(events))
+	for _, event := range events {
+		if event.StartDate.IsZero() {
+			continue
+		}
+		start := helper.FromEventClock(event.StartDate)
+		end := helper.FromEventClock(event.EndDate)
+		if end.Before(start) {
+			end = start
+		}
+		entries = append(entries, helper.ICalEvent{
+			UID:         event.ID.Hex() + "@cpeevo",
+			Summary:     event.EventName,
+			Description: event.EventDescription,
+			Start:       start,
+			End:         end,
+			Status:      "CONFIRMED",
+		})
+	}
+	return entries
+}
+
+func writeCalendar(c *gin.Context, name string, events []helper.ICalEvent, todos []helper.ICalTodo) {
+	c.Header("Content-Disposition", "inline; filename=\"calendar.ics\"")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(helper.BuildICalendar(name, events, todos)))
+}
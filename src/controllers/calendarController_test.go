@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCalendarEvents(t *testing.T) {
+	location := helper.EventLocation
+	helper.EventLocation = time.FixedZone("ICT", 7*3600)
+	defer func() { helper.EventLocation = location }()
+
+	id := primitive.NewObjectID()
+	clock := func(hour int) time.Time { return time.Date(2024, 8, 10, hour, 0, 0, 0, time.UTC) }
+	instant := func(hour int) time.Time { return clock(hour).Add(-7 * time.Hour) }
+
+	tests := []struct {
+		name   string
+		events []models.Event
+		want   []helper.ICalEvent
+	}{
+		{
+			name:   "event clock is converted to UTC",
+			events: []models.Event{{ID: id, EventName: "Camp", EventDescription: "Day one", StartDate: clock(9), EndDate: clock(17)}},
+			want:   []helper.ICalEvent{{UID: id.Hex() + "@cpeevo", Summary: "Camp", Description: "Day one", Start: instant(9), End: instant(17), Status: "CONFIRMED"}},
+		},
+		{
+			name:   "end before start",
+			events: []models.Event{{ID: id, EventName: "Talk", StartDate: clock(13), EndDate: clock(12)}},
+			want:   []helper.ICalEvent{{UID: id.Hex() + "@cpeevo", Summary: "Talk", Start: instant(13), End: instant(13), Status: "CONFIRMED"}},
+		},
+		{
+			name:   "without start date",
+			events: []models.Event{{ID: id, EventName: "Someday"}},
+			want:   []helper.ICalEvent{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendarEvents(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calendarEvents() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var checkInCollection *mongo.Collection = database.OpenCollection(database.Client, "checkins")
+
+// canCheckIn reports whether attendance can be taken in this state, which
+// covers the day itself and the time doors open before the start
+func canCheckIn(status string) bool {
+	switch status {
+	case models.EventStatusRegistrationOpen, models.EventStatusRegistrationClosed, models.EventStatusRunning:
+		return true
+	}
+	return false
+}
+
+// loadMemberEvent fetches the event in the URL and makes sure the user joined it
+func loadMemberEvent(c *gin.Context, ctx context.Context) (models.Event, string, bool) {
+	var event models.Event
+
+	userID, exists := c.Get("studentid")
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		return event, "", false
+	}
+
+	eventID, err := primitive.ObjectIDFromHex(c.Param("eventID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+		return event, "", false
+	}
+
+	if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return event, "", false
+	}
+
+	if !getMembership(event, userID).IsMember() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is not part of the event"})
+		return event, "", false
+	}
+
+	return event, userID.(string), true
+}
+
+func GetCheckInToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		event, userID, ok := loadMemberEvent(c, ctx)
+		if !ok {
+			return
+		}
+
+		token, expiresAt, err := helper.GenerateCheckInToken(userID, event.ID.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating check-in token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"token": token, "expiresAt": expiresAt}})
+	}
+}
+
+func GetCheckInQR() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		event, userID, ok := loadMemberEvent(c, ctx)
+		if !ok {
+			return
+		}
+
+		token, _, err := helper.GenerateCheckInToken(userID, event.ID.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating check-in token"})
+			return
+		}
+
+		png, err := qrcode.Encode(token, qrcode.Medium, 320)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating QR code"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
+
+// ScanCheckIn is called by staff with the token read from a student's QR code
+func ScanCheckIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		scannerID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		type ScanRequest struct {
+			Token string `json:"token" binding:"required"`
+		}
+
+		var req ScanRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, err := helper.ValidateCheckInToken(req.Token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired check-in code"})
+			return
+		}
+
+		eventID, err := primitive.ObjectIDFromHex(claims.EventID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+			return
+		}
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+			return
+		}
+
+		if !getMembership(event, scannerID).IsOrganizer() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can check students in"})
+			return
+		}
+
+		if status := currentEventStatus(event); !canCheckIn(status) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Check-in is not open for this event", "status": status})
+			return
+		}
+
+		// The student may have left the event after the code was issued
+		member := getMembership(event, claims.StudentID)
+		if !member.IsMember() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Student is not part of the event"})
+			return
+		}
+		role := "participant"
+		if member.IsStaff {
+			role = "staff"
+		}
+
+		checkIn := models.CheckIn{
+			EventID:     eventID,
+			StudentID:   claims.StudentID,
+			Role:        role,
+			CheckedInAt: time.Now().UTC(),
+			ScannedBy:   scannerID.(string),
+		}
+
+		filter := bson.M{"eventID": eventID, "studentID": claims.StudentID}
+		result, err := checkInCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": checkIn}, options.Update().SetUpsert(true))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording check-in"})
+			return
+		}
+
+		if err != nil || result.UpsertedCount == 0 {
+			var existing models.CheckIn
+			if err := checkInCollection.FindOne(ctx, filter).Decode(&existing); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading check-in"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": existing, "message": "Student already checked in"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": checkIn, "message": "Checked in successfully"})
+	}
+}
+
+// GetAttendance reports every registered member and whether they showed up
+func GetAttendance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+		access, _ := c.Get("access")
+
+		eventID, err := primitive.ObjectIDFromHex(c.Param("eventID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+			return
+		}
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+			return
+		}
+
+		if access.(int) < 3 && !getMembership(event, userID).IsOrganizer() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can view attendance"})
+			return
+		}
+
+		checkIns, err := eventCheckIns(ctx, eventID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving check-ins"})
+			return
+		}
+
+		memberIDs := append([]string{}, event.Participants...)
+		for _, staff := range event.Staff {
+			memberIDs = append(memberIDs, staff.StdID)
+		}
+		names, err := studentNames(ctx, memberIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving members"})
+			return
+		}
+
+		entry := func(stdID string, role string) models.AttendanceEntry {
+			e := models.AttendanceEntry{StdID: stdID, Name: names[stdID], Role: role}
+			if checkIn, ok := checkIns[stdID]; ok {
+				e.Attended = true
+				e.CheckedInAt = &checkIn.CheckedInAt
+			}
+			return e
+		}
+
+		attended := 0
+		participants := make([]models.AttendanceEntry, 0, len(event.Participants))
+		for _, stdID := range event.Participants {
+			e := entry(stdID, "")
+			if e.Attended {
+				attended++
+			}
+			participants = append(participants, e)
+		}
+		staff := make([]models.AttendanceEntry, 0, len(event.Staff))
+		for _, member := range event.Staff {
+			e := entry(member.StdID, member.Role)
+			if e.Attended {
+				attended++
+			}
+			staff = append(staff, e)
+		}
+
+		registered := len(participants) + len(staff)
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"eventID": eventID,
+			"summary": gin.H{
+				"registered": registered,
+				"attended":   attended,
+				"absent":     registered - attended,
+			},
+			"participants": participants,
+			"staff":        staff,
+		}})
+	}
+}
+
+// eventCheckIns returns the check-ins of an event keyed by studentID
+func eventCheckIns(ctx context.Context, eventID primitive.ObjectID) (map[string]models.CheckIn, error) {
+	cursor, err := checkInCollection.Find(ctx, bson.M{"eventID": eventID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var checkIns []models.CheckIn
+	if err := cursor.All(ctx, &checkIns); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]models.CheckIn, len(checkIns))
+	for _, checkIn := range checkIns {
+		result[checkIn.StudentID] = checkIn
+	}
+	return result, nil
+}
+
+// studentNames maps studentIDs to "firstName lastName"
+func studentNames(ctx context.Context, studentIDs []string) (map[string]string, error) {
+	names := make(map[string]string, len(studentIDs))
+	if len(studentIDs) == 0 {
+		return names, nil
+	}
+
+	projection := bson.M{"studentID": 1, "firstName": 1, "lastName": 1}
+	cursor, err := userCollection.Find(ctx, bson.M{"studentID": bson.M{"$in": studentIDs}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		names[user.StudentID] = user.FirstName + " " + user.LastName
+	}
+	return names, nil
+}
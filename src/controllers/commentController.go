@@ -0,0 +1,459 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var commentCollection *mongo.Collection = database.OpenCollection(database.Client, "comments")
+
+// maxCommentLength is the longest comment body, in characters
+const maxCommentLength = 5000
+
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_]+)`)
+
+// commentAccess is what the user may do in the discussion of a post
+type commentAccess struct {
+	post      models.Post
+	event     models.Event
+	userID    string
+	moderator bool
+}
+
+// loadCommentPost fetches a post and checks the user may read its
+// discussion, which follows the visibility of the post itself. Staff of the
+// event, its president and the department moderate.
+func loadCommentPost(c *gin.Context, ctx context.Context, postID primitive.ObjectID) (commentAccess, bool) {
+	var access commentAccess
+
+	userID, exists := c.Get("studentid")
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		return access, false
+	}
+	level, _ := c.Get("access")
+
+	if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&access.post); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return access, false
+	}
+	event, err := findPostEvent(ctx, postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return access, false
+	}
+
+	member := getMembership(event, userID)
+	access.event = event
+	access.userID = userID.(string)
+	access.moderator = level.(int) >= 3 || member.IsOrganizer()
+
+	if level.(int) < 3 && !readsPost(access.post, member) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return access, false
+	}
+	return access, true
+}
+
+// loadComment fetches a comment together with the access to its post
+func loadComment(c *gin.Context, ctx context.Context) (models.Comment, commentAccess, bool) {
+	var comment models.Comment
+
+	commentID, err := primitive.ObjectIDFromHex(c.Param("commentID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid commentID format"})
+		return comment, commentAccess{}, false
+	}
+	if err := commentCollection.FindOne(ctx, bson.M{"_id": commentID}).Decode(&comment); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return comment, commentAccess{}, false
+	}
+
+	access, ok := loadCommentPost(c, ctx, comment.PostID)
+	return comment, access, ok
+}
+
+// validCommentBody trims a comment and reports an error message when it is
+// empty or too long
+func validCommentBody(body string) (string, string) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return body, "body is required"
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return body, fmt.Sprintf("body must not be longer than %d characters", maxCommentLength)
+	}
+	return body, ""
+}
+
+// commentMentions finds the @studentID mentions of a comment that name
+// someone who can read the post, leaving out the author
+func commentMentions(access commentAccess, body string) []string {
+	var mentions []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		studentID := match[1]
+		if studentID == access.userID || containsString(mentions, studentID) {
+			continue
+		}
+		if readsPost(access.post, getMembership(access.event, studentID)) {
+			mentions = append(mentions, studentID)
+		}
+	}
+	return mentions
+}
+
+func notifyMentions(ctx context.Context, access commentAccess, comment models.Comment, studentIDs []string) error {
+	names, err := studentNames(ctx, []string{comment.AuthorID})
+	if err != nil {
+		return err
+	}
+	author := names[comment.AuthorID]
+	if author == "" {
+		author = comment.AuthorID
+	}
+
+	postID, commentID := comment.PostID, comment.ID
+	return notifyStudents(ctx, studentIDs, models.Notification{
+		Kind:      models.NotificationMention,
+		EventID:   access.event.ID,
+		PostID:    &postID,
+		CommentID: &commentID,
+		Title:     access.post.Title,
+		Message:   author + " mentioned you in a comment",
+	})
+}
+
+// commentThread is a comment with its replies, as listed to the user
+type commentThread struct {
+	models.Comment
+	AuthorName string           `json:"authorName"`
+	Replies    []*commentThread `json:"replies"`
+}
+
+// GetComments lists the discussion of a post as threads, oldest first. The
+// body of hidden comments is only shown to moderators, deleted comments
+// keep their place without a body.
+func GetComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		access, ok := loadCommentPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+		cursor, err := commentCollection.Find(ctx, bson.M{"postID": postID}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var comments []models.Comment
+		if err := cursor.All(ctx, &comments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		authors := make([]string, 0, len(comments))
+		for _, comment := range comments {
+			authors = append(authors, comment.AuthorID)
+		}
+		names, err := studentNames(ctx, authors)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		threads := make(map[primitive.ObjectID]*commentThread, len(comments))
+		roots := []*commentThread{}
+		for _, comment := range comments {
+			if comment.Deleted || (comment.Hidden && !access.moderator) {
+				comment.Body = ""
+				comment.Mentions = nil
+			}
+			thread := &commentThread{Comment: comment, AuthorName: names[comment.AuthorID], Replies: []*commentThread{}}
+			threads[comment.ID] = thread
+
+			// Parents are older than their replies, so they are already known
+			if comment.ParentID != nil {
+				if parent, ok := threads[*comment.ParentID]; ok {
+					parent.Replies = append(parent.Replies, thread)
+					continue
+				}
+			}
+			roots = append(roots, thread)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"locked":   access.post.CommentsLocked,
+			"comments": roots,
+		}})
+	}
+}
+
+// CreateComment adds a comment to a post, or a reply when parentID is given
+func CreateComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		var request struct {
+			Body     string              `json:"body"`
+			ParentID *primitive.ObjectID `json:"parentID"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		body, msg := validCommentBody(request.Body)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		access, ok := loadCommentPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+		if access.post.CommentsLocked && !access.moderator {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Comments on this post are locked"})
+			return
+		}
+
+		if request.ParentID != nil {
+			var parent models.Comment
+			if err := commentCollection.FindOne(ctx, bson.M{"_id": *request.ParentID, "postID": postID}).Decode(&parent); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment not found"})
+				return
+			}
+			if parent.Deleted || parent.Hidden {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reply to a removed comment"})
+				return
+			}
+		}
+
+		comment := models.Comment{
+			ID:        primitive.NewObjectID(),
+			PostID:    postID,
+			ParentID:  request.ParentID,
+			AuthorID:  access.userID,
+			Body:      body,
+			Mentions:  commentMentions(access, body),
+			CreatedAt: time.Now().UTC(),
+		}
+		if _, err := commentCollection.InsertOne(ctx, comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := notifyMentions(ctx, access, comment, comment.Mentions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": comment})
+	}
+}
+
+// EditComment changes the body of a comment, for its author only. Students
+// newly mentioned are notified.
+func EditComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Body string `json:"body"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		body, msg := validCommentBody(request.Body)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		comment, access, ok := loadComment(c, ctx)
+		if !ok {
+			return
+		}
+		if comment.AuthorID != access.userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+			return
+		}
+		if comment.Deleted || comment.Hidden {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Comment was removed"})
+			return
+		}
+		if access.post.CommentsLocked && !access.moderator {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Comments on this post are locked"})
+			return
+		}
+
+		mentions := commentMentions(access, body)
+		var added []string
+		for _, studentID := range mentions {
+			if !containsString(comment.Mentions, studentID) {
+				added = append(added, studentID)
+			}
+		}
+
+		now := time.Now().UTC()
+		update := bson.M{"$set": bson.M{"body": body, "mentions": mentions, "editedAt": now}}
+		if _, err := commentCollection.UpdateOne(ctx, bson.M{"_id": comment.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		comment.Body = body
+		comment.Mentions = mentions
+		comment.EditedAt = &now
+
+		if err := notifyMentions(ctx, access, comment, added); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": comment})
+	}
+}
+
+// DeleteComment removes a comment of the logged in student. Its replies
+// stay, under a comment without a body.
+func DeleteComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		comment, access, ok := loadComment(c, ctx)
+		if !ok {
+			return
+		}
+		if comment.AuthorID != access.userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments, moderators hide them"})
+			return
+		}
+
+		update := bson.M{
+			"$set":   bson.M{"deleted": true, "body": ""},
+			"$unset": bson.M{"mentions": ""},
+		}
+		if _, err := commentCollection.UpdateOne(ctx, bson.M{"_id": comment.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "comment deleted"})
+	}
+}
+
+// HideComment lets a moderator hide or show again a comment
+func HideComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Hidden bool `json:"hidden"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		comment, access, ok := loadComment(c, ctx)
+		if !ok {
+			return
+		}
+		if !access.moderator {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can moderate comments"})
+			return
+		}
+
+		update := bson.M{"$set": bson.M{"hidden": true, "hiddenBy": access.userID}}
+		if !request.Hidden {
+			update = bson.M{"$unset": bson.M{"hidden": "", "hiddenBy": ""}}
+		}
+		if _, err := commentCollection.UpdateOne(ctx, bson.M{"_id": comment.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"_id": comment.ID, "hidden": request.Hidden}})
+	}
+}
+
+// LockComments lets a moderator close or reopen the discussion of a post
+func LockComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		var request struct {
+			Locked bool `json:"locked"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		access, ok := loadCommentPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+		if !access.moderator {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can moderate comments"})
+			return
+		}
+
+		if _, err := postCollection.UpdateOne(ctx, bson.M{"_id": postID}, bson.M{"$set": bson.M{"commentsLocked": request.Locked}}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"postID": postID, "locked": request.Locked}})
+	}
+}
+
+// deleteComments removes the discussion of a post
+func deleteComments(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := commentCollection.DeleteMany(ctx, bson.M{"postID": postID})
+	return err
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error codes of rejected late submissions
+const (
+	ErrCodeDeadlinePassed = "DEADLINE_PASSED"
+)
+
+// studentDeadline is the instant the post closes for a student, taking a
+// personal extension into account. The second value is false for posts
+// without an end date.
+func studentDeadline(post models.Post, studentID string) (time.Time, bool) {
+	if post.EndDate == nil {
+		return time.Time{}, false
+	}
+	end := post.EndDate.Time()
+	for _, extension := range post.Extensions {
+		if extension.StudentID == studentID && extension.EndDate.Time().After(end) {
+			end = extension.EndDate.Time()
+		}
+	}
+	return helper.FromEventClock(end), true
+}
+
+// postClosed reports whether the end date of the post has passed
+func postClosed(post models.Post) bool {
+	return postClosedFor(post, "")
+}
+
+// postClosedFor is postClosed with the extension of a student applied
+func postClosedFor(post models.Post, studentID string) bool {
+	deadline, ok := studentDeadline(post, studentID)
+	return ok && time.Now().UTC().After(deadline)
+}
+
+// acceptsAnswersFrom reports whether a change to the answer of a student is
+// still on time. The grace period absorbs requests sent just before closing.
+func acceptsAnswersFrom(post models.Post, studentID string, now time.Time) bool {
+	deadline, ok := studentDeadline(post, studentID)
+	if !ok {
+		return true
+	}
+	grace := time.Duration(post.GracePeriod) * time.Minute
+	return !now.After(deadline.Add(grace))
+}
+
+// rejectLateAnswer writes the deadline error and reports whether it did
+func rejectLateAnswer(c *gin.Context, post models.Post, studentID string) bool {
+	if acceptsAnswersFrom(post, studentID, time.Now().UTC()) {
+		return false
+	}
+	deadline, _ := studentDeadline(post, studentID)
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":    "The deadline for this post has passed",
+		"code":     ErrCodeDeadlinePassed,
+		"deadline": deadline,
+	})
+	return true
+}
+
+// GetExtensions lists the personal deadlines of a post
+func GetExtensions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid postID format"})
+			return
+		}
+
+		post, _, ok := loadOrganizedPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+
+		extensions := post.Extensions
+		if extensions == nil {
+			extensions = []models.DeadlineExtension{}
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": extensions})
+	}
+}
+
+// SetExtension gives one student a later end date than the rest
+func SetExtension() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		type ExtensionRequest struct {
+			PostID    primitive.ObjectID `json:"postID" binding:"required"`
+			StudentID string             `json:"studentID" binding:"required"`
+			EndDate   primitive.DateTime `json:"endDate" binding:"required"`
+		}
+
+		var req ExtensionRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		post, event, ok := loadOrganizedPost(c, ctx, req.PostID)
+		if !ok {
+			return
+		}
+
+		if post.EndDate == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Post has no deadline to extend"})
+			return
+		}
+		if !getMembership(event, req.StudentID).IsMember() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Student is not a member of the event"})
+			return
+		}
+
+		// Replace any previous extension of the student in a single write
+		extension := models.DeadlineExtension{StudentID: req.StudentID, EndDate: req.EndDate}
+		others := bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$extensions", bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this.studentID", req.StudentID}},
+		}}
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"extensions": bson.M{"$concatArrays": bson.A{others, bson.A{bson.M{"$literal": extension}}}},
+		}}}}
+		if _, err := postCollection.UpdateOne(ctx, bson.M{"_id": req.PostID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": extension})
+	}
+}
+
+// DeleteExtension puts a student back on the deadline of the post
+func DeleteExtension() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid postID format"})
+			return
+		}
+		studentID := c.Param("studentID")
+
+		if _, _, ok := loadOrganizedPost(c, ctx, postID); !ok {
+			return
+		}
+
+		result, err := postCollection.UpdateOne(ctx, bson.M{"_id": postID}, bson.M{"$pull": bson.M{"extensions": bson.M{"studentID": studentID}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.ModifiedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Extension not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "extension removed"})
+	}
+}
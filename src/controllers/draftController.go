@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// submittedAnswers selects the answers of a post that are not drafts
+func submittedAnswers(postID primitive.ObjectID) bson.M {
+	return bson.M{"postID": postID, "status": bson.M{"$ne": models.AnswerStatusDraft}}
+}
+
+// SaveDraft stores the answers of a form as they are, so a long form can be
+// filled in several sittings. The draft is not validated until it is
+// submitted with posts/submit.
+func SaveDraft() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+		studentID := userID.(string)
+
+		post, body, ok := bindAnswerPost(c, ctx)
+		if !ok {
+			return
+		}
+		kind, err := lookupPostKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+		if !kind.traits().drafts {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This kind of post cannot be saved as a draft"})
+			return
+		}
+		if rejectLateAnswer(c, post, studentID) {
+			return
+		}
+
+		var request struct {
+			AnswerList []models.AQuestion `json:"answerList"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.AnswerList == nil {
+			request.AnswerList = []models.AQuestion{}
+		}
+
+		now := time.Now().UTC()
+		previous, err := findOwnAnswer(ctx, post.ID, studentID)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			draft := models.AForm{
+				ID:         primitive.NewObjectID(),
+				PostID:     post.ID,
+				StudentID:  studentID,
+				AnswerList: request.AnswerList,
+				AnswerMeta: models.AnswerMeta{Status: models.AnswerStatusDraft, UpdatedAt: now, Version: 1},
+			}
+			_, err := transactionCollection.InsertOne(ctx, draft)
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Answer was changed by another request, try again"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": draft, "message": "draft saved"})
+
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		case previous.Status != models.AnswerStatusDraft:
+			c.JSON(http.StatusConflict, gin.H{"error": "Answer already submitted, edit it instead"})
+
+		default:
+			// Drafts change too often to keep their history
+			filter := bson.M{"_id": previous.ID, "status": models.AnswerStatusDraft}
+			update := bson.M{
+				"$set": bson.M{"answerList": request.AnswerList, "updatedAt": now},
+				"$inc": bson.M{"version": 1},
+			}
+			result, err := transactionCollection.UpdateOne(ctx, filter, update)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if result.MatchedCount == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Answer already submitted, edit it instead"})
+				return
+			}
+
+			draft := models.AForm{
+				ID:         previous.ID,
+				PostID:     post.ID,
+				StudentID:  studentID,
+				AnswerList: request.AnswerList,
+				AnswerMeta: models.AnswerMeta{Status: models.AnswerStatusDraft, UpdatedAt: now, Version: previous.Version + 1},
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": draft, "message": "draft saved"})
+		}
+	}
+}
+
+// withDraftAnswers fills in the answers of a submission that has none with
+// those of the draft, so a draft can be submitted as it is
+func withDraftAnswers(body []byte, draft storedAnswer) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	if _, ok := request["answerList"]; ok {
+		return body, nil
+	}
+
+	var form models.AForm
+	if err := bson.Unmarshal(draft.doc, &form); err != nil {
+		return nil, err
+	}
+	answers, err := json.Marshal(form.AnswerList)
+	if err != nil {
+		return nil, err
+	}
+	request["answerList"] = answers
+	return json.Marshal(request)
+}
+
+// PurgeExpiredDrafts deletes the drafts, and the files sent for them, of
+// students whose deadline has passed
+func PurgeExpiredDrafts() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	postIDs, err := transactionCollection.Distinct(ctx, "postID", bson.M{"status": models.AnswerStatusDraft})
+	if err != nil || len(postIDs) == 0 {
+		return err
+	}
+
+	cursor, err := postCollection.Find(ctx, bson.M{"_id": bson.M{"$in": postIDs}, "endDate": bson.M{"$ne": nil}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	purged := 0
+	for _, post := range posts {
+		students, err := transactionCollection.Distinct(ctx, "studentID", bson.M{"postID": post.ID, "status": models.AnswerStatusDraft})
+		if err != nil {
+			return err
+		}
+
+		var expired []string
+		for _, student := range students {
+			if studentID, ok := student.(string); ok && !acceptsAnswersFrom(post, studentID, now) {
+				expired = append(expired, studentID)
+			}
+		}
+		if len(expired) == 0 {
+			continue
+		}
+
+		filter := bson.M{"postID": post.ID, "studentID": bson.M{"$in": expired}, "status": models.AnswerStatusDraft}
+		result, err := transactionCollection.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		purged += int(result.DeletedCount)
+
+		// A student with a draft has no submitted answer using the files
+		if err := deleteUploadsMatching(ctx, bson.M{"postID": post.ID, "studentID": bson.M{"$in": expired}}); err != nil {
+			return err
+		}
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d expired draft answers", purged)
+	}
+	return nil
+}
@@ -32,8 +32,23 @@ func CreateNewEvent() gin.HandlerFunc {
 			return
 		}
 
+		switch event.Status {
+		case "":
+			event.Status = models.EventStatusPublished
+		case models.EventStatusDraft, models.EventStatusPublished:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New events must be draft or published"})
+			return
+		}
+		if msg := validateEventSchedule(event); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		event.Status = currentEventStatus(event)
+
 		event.Participants = []string{}
 		event.Staff = []models.StaffMember{}
+		event.Waitlist = []models.WaitlistEntry{}
 		event.PostList = []primitive.ObjectID{}
 
 		eventName := event.EventName
@@ -50,6 +65,12 @@ func CreateNewEvent() gin.HandlerFunc {
 			return
 		}
 
+		// Icons and posters sent inline are kept in the media storage instead
+		event.ID = result.InsertedID.(primitive.ObjectID)
+		if err := moveInlineEventImages(ctx, event); err != nil {
+			log.Printf("Error moving the images of event %s: %v", event.ID.Hex(), err)
+		}
+
 		c.JSON(http.StatusOK, gin.H{"data": result, "message": "Event created successfully"})
 	}
 }
@@ -79,6 +100,14 @@ func GetEvent() gin.HandlerFunc {
 			return
 		}
 
+		studentid, _ := c.Get("studentid")
+		if event.Status == models.EventStatusDraft && (event.President == nil || *event.President != studentid) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+			return
+		}
+		event.Status = currentEventStatus(event)
+		setOwnWaitlistPosition(&event, studentid)
+
 		c.JSON(http.StatusOK, gin.H{"data": event})
 	}
 }
@@ -108,6 +137,11 @@ func UpdateEvent() gin.HandlerFunc {
 
 		objectID := req.ID
 
+		if msg := validateEventSchedule(req); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
 		studentid, exists := c.Get("studentid")
 		if !exists {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "studentid not found"})
@@ -142,10 +176,13 @@ func UpdateEvent() gin.HandlerFunc {
 				{Key: "kind", Value: req.Kind},
 				{Key: "startDate", Value: req.StartDate},
 				{Key: "endDate", Value: req.EndDate},
+				{Key: "hours", Value: req.Hours},
 				{Key: "nParticipant", Value: req.NParticipant},
 				{Key: "nStaff", Value: req.NStaff},
 				{Key: "role", Value: req.Role},
 				{Key: "president", Value: req.President},
+				{Key: "registrationOpen", Value: req.RegistrationOpen},
+				{Key: "registrationClose", Value: req.RegistrationClose},
 			}},
 		}
 
@@ -156,6 +193,11 @@ func UpdateEvent() gin.HandlerFunc {
 			return
 		}
 
+		// Raising the capacity frees places for waitlisted students
+		if _, err := PromoteWaitlist(objectID); err != nil {
+			log.Printf("Error promoting waitlist for event %s: %v", objectID.Hex(), err)
+		}
+
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -185,6 +227,10 @@ func DeleteEvent() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 
+		if err := deleteEventMedia(ctx, eventID); err != nil {
+			log.Printf("Error deleting the images of event %s: %v", eventID.Hex(), err)
+		}
+
 		filterEventDelete := bson.M{"_id": eventID}
 		_, err = eventCollection.DeleteOne(ctx, filterEventDelete)
 		if err != nil {
@@ -248,7 +294,7 @@ func GetEvents() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		var events []models.Event
 
-		cursor, err := eventCollection.Find(ctx, bson.M{})
+		cursor, err := eventCollection.Find(ctx, visibleEventsFilter(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -260,10 +306,28 @@ func GetEvents() gin.HandlerFunc {
 			return
 		}
 
+		studentid, _ := c.Get("studentid")
+		for i := range events {
+			events[i].Status = currentEventStatus(events[i])
+			setOwnWaitlistPosition(&events[i], studentid)
+		}
+
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": events})
 	}
 }
 
+// visibleEventsFilter hides draft events from everyone but their president
+func visibleEventsFilter(c *gin.Context) bson.M {
+	studentid, exists := c.Get("studentid")
+	if !exists {
+		return bson.M{"status": bson.M{"$ne": models.EventStatusDraft}}
+	}
+	return bson.M{"$or": []bson.M{
+		{"status": bson.M{"$ne": models.EventStatusDraft}},
+		{"president": studentid},
+	}}
+}
+
 func SearchEvents() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Query("name")
@@ -274,7 +338,8 @@ func SearchEvents() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		var events []models.Event
 
-		query := bson.M{"eventName": bson.M{"$regex": name, "$options": "i"}}
+		query := visibleEventsFilter(c)
+		query["eventName"] = bson.M{"$regex": name, "$options": "i"}
 
 		cursor, err := eventCollection.Find(ctx, query)
 		if err != nil {
@@ -288,6 +353,12 @@ func SearchEvents() gin.HandlerFunc {
 			return
 		}
 
+		studentid, _ := c.Get("studentid")
+		for i := range events {
+			events[i].Status = currentEventStatus(events[i])
+			setOwnWaitlistPosition(&events[i], studentid)
+		}
+
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": events})
 	}
 }
@@ -367,6 +438,11 @@ func JoinEvent() gin.HandlerFunc {
 			return
 		}
 
+		if status := currentEventStatus(event); status != models.EventStatusRegistrationOpen {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Registration is not open", "status": status})
+			return
+		}
+
 		// Check if user is already a staff member or participant
 		isStaff := false
 		isParticipant := false
@@ -388,33 +464,77 @@ func JoinEvent() gin.HandlerFunc {
 			return
 		}
 
-		var staffMember models.StaffMember
-		update := bson.D{}
-		if joinRequest.Role == "staff" {
-			staffMember.StdID = userID.(string)
-			staffMember.Role = joinRequest.SubRole
-			update = bson.D{
-				{"$addToSet", bson.D{
-					{"staff", staffMember},
-				}},
+		if (joinRequest.Role == "staff" && isStaff) || (joinRequest.Role == "participant" && isParticipant) {
+			c.JSON(http.StatusConflict, gin.H{"message": "User already in event"})
+			return
+		}
+
+		for _, entry := range event.Waitlist {
+			if entry.StdID == userID {
+				c.JSON(http.StatusConflict, gin.H{"error": "User is already on the waitlist"})
+				return
 			}
+		}
+
+		var member interface{}
+		var limit *int
+		var field string
+		if joinRequest.Role == "staff" {
+			member = models.StaffMember{StdID: userID.(string), Role: joinRequest.SubRole}
+			limit = event.NStaff
+			field = "staff"
 		} else if joinRequest.Role == "participant" {
-			update = bson.D{
-				{"$addToSet", bson.D{
-					{"participants", userID},
-				}},
-			}
+			member = userID
+			limit = event.NParticipant
+			field = "participants"
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
 			return
 		}
 
-		result, err := eventCollection.UpdateOne(ctx, bson.M{"_id": eventID}, update)
+		// The capacity check is part of the filter so that two concurrent joins
+		// can never push the event past its limit
+		filter := bson.M{"_id": eventID}
+		if limit != nil {
+			filter["$expr"] = capacityExpr(field, *limit)
+		}
+		update := bson.M{"$addToSet": bson.M{field: member}}
+
+		result, err := eventCollection.UpdateOne(ctx, filter, update)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error joining event"})
 			return
 		}
 
+		if result.MatchedCount == 0 {
+			// Event is full, put the user on the waitlist instead
+			entry := models.WaitlistEntry{
+				StdID:    userID.(string),
+				Role:     joinRequest.Role,
+				SubRole:  joinRequest.SubRole,
+				JoinedAt: time.Now().UTC(),
+			}
+			waitlistFilter := bson.M{"_id": eventID, "waitlist.stdID": bson.M{"$ne": userID}}
+			result, err = eventCollection.UpdateOne(ctx, waitlistFilter, bson.M{"$push": bson.M{"waitlist": entry}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error joining waitlist"})
+				return
+			}
+			if result.ModifiedCount == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "User is already on the waitlist"})
+				return
+			}
+
+			if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Event not found"})
+				return
+			}
+			position, length := waitlistPosition(event, userID.(string))
+
+			c.JSON(http.StatusAccepted, gin.H{"data": gin.H{"role": entry.Role, "position": position, "length": length}, "message": "Event is full, added to waitlist"})
+			return
+		}
+
 		if result.ModifiedCount == 0 {
 			c.JSON(http.StatusConflict, gin.H{"message": "User already in event"})
 			return
@@ -458,6 +578,11 @@ func LeaveEvent() gin.HandlerFunc {
 			return
 		}
 
+		if status := currentEventStatus(event); !canLeaveEvent(status) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Event can no longer be left", "status": status})
+			return
+		}
+
 		// Check if user is a staff member or participant
 		isStaff := false
 		isParticipant := false
@@ -475,7 +600,17 @@ func LeaveEvent() gin.HandlerFunc {
 		}
 
 		if !isStaff && !isParticipant {
-			c.JSON(http.StatusConflict, gin.H{"error": "User is not part of the event"})
+			// A waitlisted user leaving only gives up their place in the queue
+			result, err := eventCollection.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$pull": bson.M{"waitlist": bson.M{"stdID": userID}}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leaving waitlist"})
+				return
+			}
+			if result.ModifiedCount == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "User is not part of the event"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"data": result, "message": "Left waitlist successfully"})
 			return
 		}
 
@@ -505,7 +640,160 @@ func LeaveEvent() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": result, "message": "Left event successfully"})
+		promoted, err := PromoteWaitlist(eventID)
+		if err != nil {
+			log.Printf("Error promoting waitlist for event %s: %v", eventID.Hex(), err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": result, "promoted": promoted, "message": "Left event successfully"})
+	}
+}
+
+func GetWaitlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		objectID, err := primitive.ObjectIDFromHex(c.Param("eventID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+			return
+		}
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+			return
+		}
+
+		position, length := waitlistPosition(event, userID.(string))
+		data := gin.H{"position": position, "length": length}
+
+		// The president can see the whole queue
+		if event.President != nil && *event.President == userID {
+			waitlist := event.Waitlist
+			if waitlist == nil {
+				waitlist = []models.WaitlistEntry{}
+			}
+			data["waitlist"] = waitlist
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+	}
+}
+
+// PromoteWaitlist moves the oldest waitlisted students into the event while
+// there is capacity left for their role. Each promotion is a single
+// conditional update, so it never overbooks even with concurrent joins.
+func PromoteWaitlist(eventID primitive.ObjectID) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	promoted := []string{}
+	for _, role := range []string{"participant", "staff"} {
+		for {
+			var event models.Event
+			if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+				return promoted, err
+			}
+
+			var next *models.WaitlistEntry
+			for i := range event.Waitlist {
+				if event.Waitlist[i].Role == role {
+					next = &event.Waitlist[i]
+					break
+				}
+			}
+			if next == nil {
+				break
+			}
+
+			var member interface{} = next.StdID
+			limit := event.NParticipant
+			field := "participants"
+			if role == "staff" {
+				member = models.StaffMember{StdID: next.StdID, Role: next.SubRole}
+				limit = event.NStaff
+				field = "staff"
+			}
+
+			filter := bson.M{"_id": eventID, "waitlist.stdID": next.StdID}
+			if limit != nil {
+				filter["$expr"] = capacityExpr(field, *limit)
+			}
+			update := bson.M{
+				"$pull":     bson.M{"waitlist": bson.M{"stdID": next.StdID}},
+				"$addToSet": bson.M{field: member},
+			}
+
+			result, err := eventCollection.UpdateOne(ctx, filter, update)
+			if err != nil {
+				return promoted, err
+			}
+			if result.MatchedCount == 0 {
+				// Either the role is full or someone else promoted this entry first
+				stillWaiting := false
+				if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID, "waitlist.stdID": next.StdID}).Err(); err == nil {
+					stillWaiting = true
+				}
+				if stillWaiting {
+					break
+				}
+				continue
+			}
+			promoted = append(promoted, next.StdID)
+		}
+	}
+
+	return promoted, nil
+}
+
+// capacityExpr matches an event only while the given member array is below limit
+func capacityExpr(field string, limit int) bson.M {
+	return bson.M{"$lt": bson.A{
+		bson.M{"$size": bson.M{"$ifNull": bson.A{"$" + field, bson.A{}}}},
+		limit,
+	}}
+}
+
+// waitlistPosition returns the 1-based position of a student in the queue for
+// their role (0 if not waitlisted) and the length of that queue
+func waitlistPosition(event models.Event, stdID string) (int, int) {
+	role := ""
+	for _, entry := range event.Waitlist {
+		if entry.StdID == stdID {
+			role = entry.Role
+			break
+		}
+	}
+	if role == "" {
+		return 0, 0
+	}
+
+	position, length := 0, 0
+	for _, entry := range event.Waitlist {
+		if entry.Role != role {
+			continue
+		}
+		length++
+		if entry.StdID == stdID {
+			position = length
+		}
+	}
+	return position, length
+}
+
+// setOwnWaitlistPosition fills in where the student asking stands in the
+// waitlist of the event, the queue itself is never sent
+func setOwnWaitlistPosition(event *models.Event, studentID interface{}) {
+	if id, ok := studentID.(string); ok {
+		event.WaitlistPosition, _ = waitlistPosition(*event, id)
 	}
 }
 
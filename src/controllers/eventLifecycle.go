@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// archiveAfter is how long a finished event stays visible before it is archived
+var archiveAfter = archiveDelay()
+
+func archiveDelay() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("EVENT_ARCHIVE_AFTER_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// eventStatusAt derives the lifecycle state of an event at the given time.
+// Draft and archived are only left through UpdateEventStatus, every other
+// state follows from the registration window and the event dates.
+func eventStatusAt(event models.Event, now time.Time) string {
+	switch event.Status {
+	case models.EventStatusDraft, models.EventStatusArchived:
+		return event.Status
+	}
+
+	if !event.EndDate.IsZero() && !now.Before(event.EndDate) {
+		if now.After(event.EndDate.Add(archiveAfter)) {
+			return models.EventStatusArchived
+		}
+		return models.EventStatusFinished
+	}
+	if !event.StartDate.IsZero() && !now.Before(event.StartDate) {
+		return models.EventStatusRunning
+	}
+	if event.RegistrationClose != nil && !now.Before(*event.RegistrationClose) {
+		return models.EventStatusRegistrationClosed
+	}
+	if event.RegistrationOpen == nil || !now.Before(*event.RegistrationOpen) {
+		return models.EventStatusRegistrationOpen
+	}
+	return models.EventStatusPublished
+}
+
+// currentEventStatus is eventStatusAt for the current time. Event dates are
+// stored on the event clock, so the current time is converted to it.
+func currentEventStatus(event models.Event) string {
+	return eventStatusAt(event, helper.ToEventClock(time.Now()))
+}
+
+// canLeaveEvent reports whether members may still drop out, which is only
+// possible before the event starts
+func canLeaveEvent(status string) bool {
+	switch status {
+	case models.EventStatusPublished, models.EventStatusRegistrationOpen, models.EventStatusRegistrationClosed:
+		return true
+	}
+	return false
+}
+
+// validateEventSchedule checks that the registration window fits the event dates
+func validateEventSchedule(event models.Event) string {
+	if !event.StartDate.IsZero() && !event.EndDate.IsZero() && event.EndDate.Before(event.StartDate) {
+		return "endDate must not be before startDate"
+	}
+	if event.RegistrationOpen != nil && event.RegistrationClose != nil && event.RegistrationClose.Before(*event.RegistrationOpen) {
+		return "registrationClose must not be before registrationOpen"
+	}
+	if event.RegistrationClose != nil && !event.EndDate.IsZero() && event.RegistrationClose.After(event.EndDate) {
+		return "registrationClose must not be after endDate"
+	}
+	if event.Hours != nil && *event.Hours < 0 {
+		return "hours must not be negative"
+	}
+	if event.Hours != nil && !event.StartDate.IsZero() && !event.EndDate.IsZero() && *event.Hours > event.EndDate.Sub(event.StartDate).Hours() {
+		return "hours must not be more than the length of the event"
+	}
+	return ""
+}
+
+// UpdateEventStatus handles the manual transitions: publishing a draft,
+// moving an event without members back to draft, and archiving.
+func UpdateEventStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		type StatusRequest struct {
+			EventID string `json:"eventID" binding:"required"`
+			Status  string `json:"status" binding:"required"`
+		}
+
+		var req StatusRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		eventID, err := primitive.ObjectIDFromHex(req.EventID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+			return
+		}
+
+		studentid, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+			return
+		}
+
+		if access == 2 && (event.President == nil || *event.President != studentid) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the president can change the event status"})
+			return
+		}
+
+		current := currentEventStatus(event)
+		next := ""
+		switch req.Status {
+		case models.EventStatusPublished:
+			if event.Status != models.EventStatusDraft {
+				c.JSON(http.StatusConflict, gin.H{"error": "Only draft events can be published"})
+				return
+			}
+			event.Status = models.EventStatusPublished
+			next = currentEventStatus(event)
+		case models.EventStatusDraft:
+			if len(event.Participants) > 0 || len(event.Staff) > 0 || len(event.Waitlist) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Events with members cannot go back to draft"})
+				return
+			}
+			next = models.EventStatusDraft
+		case models.EventStatusArchived:
+			next = models.EventStatusArchived
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status can only be set to draft, published or archived"})
+			return
+		}
+
+		if _, err := eventCollection.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$set": bson.M{"status": next}}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"previous": current, "status": next}})
+	}
+}
+
+// AdvanceEventStatuses stores the time-driven state of every live event, so
+// that queries on the status field stay accurate without recomputing it.
+func AdvanceEventStatuses() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": bson.M{"$nin": []string{models.EventStatusDraft, models.EventStatusArchived}}}
+	cursor, err := eventCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return err
+	}
+
+	now := helper.ToEventClock(time.Now())
+	for _, event := range events {
+		next := eventStatusAt(event, now)
+		if next == event.Status {
+			continue
+		}
+
+		// Only advance from the state we read, a manual change wins. Events
+		// from before the lifecycle existed have no status at all.
+		var current interface{} = event.Status
+		if event.Status == "" {
+			current = bson.M{"$in": bson.A{nil, ""}}
+		}
+		_, err := eventCollection.UpdateOne(ctx,
+			bson.M{"_id": event.ID, "status": current},
+			bson.M{"$set": bson.M{"status": next}},
+		)
+		if err != nil {
+			log.Printf("Error advancing event %s: %v", event.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Event %s moved from %q to %q", event.ID.Hex(), event.Status, next)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Names are looked up for this many respondents at a time while exporting
+const exportBatchSize = 200
+
+// rowWriter is a spreadsheet being streamed to the client
+type rowWriter interface {
+	WriteRow(cells []string) error
+	Flush() error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (r csvRowWriter) WriteRow(cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = helper.EscapeFormula(cell)
+	}
+	return r.w.Write(escaped)
+}
+
+func (r csvRowWriter) Flush() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+func (r csvRowWriter) Close() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// ExportAnswers streams the answers of a form or the tallies of a vote as a
+// spreadsheet, chosen with ?format=csv|xlsx
+func ExportAnswers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID"})
+			return
+		}
+
+		post, withRespondents, ok := loadSummaryPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+		kind, err := lookupAnswerKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+
+		filename := "post-" + postID.Hex() + "-" + post.Kind
+		var rows rowWriter
+		switch c.DefaultQuery("format", "csv") {
+		case "csv":
+			c.Header("Content-Disposition", "attachment; filename=\""+filename+".csv\"")
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			rows = csvRowWriter{w: csv.NewWriter(c.Writer)}
+		case "xlsx":
+			c.Header("Content-Disposition", "attachment; filename=\""+filename+".xlsx\"")
+			c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+			rows, err = helper.NewXLSXWriter(c.Writer, post.Title)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+			return
+		}
+		c.Status(http.StatusOK)
+
+		// Headers are sent at this point, errors can only end the download early
+		err = kind.export(ctx, post, withRespondents, rows, func() error {
+			if err := rows.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		})
+		if err == nil {
+			err = rows.Close()
+		}
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// exportForm writes one row per respondent and one column per question
+func exportForm(ctx context.Context, post models.Post, withRespondents bool, rows rowWriter, flush func() error) error {
+	header := []string{"Submitted At"}
+	if withRespondents {
+		header = []string{"Student ID", "Name", "Submitted At"}
+	}
+	for _, question := range post.FormQuestions {
+		header = append(header, question.Question)
+	}
+	if err := rows.WriteRow(header); err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "submittedAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := transactionCollection.Find(ctx, submittedAnswers(post.ID), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]models.AForm, 0, exportBatchSize)
+	writeBatch := func() error {
+		// Answers to questions that were edited since are left out of the new columns
+		answers, _, err := answersToCurrentQuestions(ctx, post, batch)
+		if err != nil {
+			return err
+		}
+
+		var names map[string]string
+		if withRespondents {
+			ids := make([]string, 0, len(answers))
+			for _, answer := range answers {
+				ids = append(ids, answer.StudentID)
+			}
+			if names, err = studentNames(ctx, ids); err != nil {
+				return err
+			}
+		}
+
+		for _, answer := range answers {
+			if err := rows.WriteRow(formAnswerRow(post, answer, withRespondents, names)); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return flush()
+	}
+
+	for cursor.Next(ctx) {
+		var answer models.AForm
+		if err := cursor.Decode(&answer); err != nil {
+			return err
+		}
+		batch = append(batch, answer)
+		if len(batch) == exportBatchSize {
+			if err := writeBatch(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return writeBatch()
+}
+
+func formAnswerRow(post models.Post, answer models.AForm, withRespondents bool, names map[string]string) []string {
+	submittedAt := ""
+	if !answer.SubmittedAt.IsZero() {
+		submittedAt = answer.SubmittedAt.UTC().Format(time.RFC3339)
+	}
+
+	row := []string{submittedAt}
+	if withRespondents {
+		row = []string{answer.StudentID, names[answer.StudentID], submittedAt}
+	}
+
+	cells := make([]string, len(post.FormQuestions))
+	for _, question := range answer.AnswerList {
+		if question.QuestionIndex >= 0 && question.QuestionIndex < len(cells) {
+			cells[question.QuestionIndex] = strings.Join(question.Answers, "; ")
+		}
+	}
+	return append(row, cells...)
+}
+
+// exportVote writes the tally of every option of every question, first
+// preferences for ranked questions
+func exportVote(ctx context.Context, post models.Post, rows rowWriter) error {
+	votes, err := loadVoteChoices(ctx, post)
+	if err != nil {
+		return err
+	}
+
+	if err := rows.WriteRow([]string{"Question #", "Question", "Method", "Option", "Votes", "Winner"}); err != nil {
+		return err
+	}
+	for i, question := range ballotQuestions(post) {
+		result := tallyVoteQuestion(i, question, votes)
+		for _, count := range result.Results {
+			winner := ""
+			if containsString(result.Winners, count.Option) {
+				winner = "yes"
+			}
+			row := []string{strconv.Itoa(i + 1), result.Question, result.Method, count.Option, strconv.Itoa(count.Count), winner}
+			if err := rows.WriteRow(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
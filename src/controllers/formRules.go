@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+// formAnswerMap indexes the answers of a submission by question, keeping the
+// first answer when a question is answered twice
+func formAnswerMap(form models.AForm) map[int][]string {
+	answers := make(map[int][]string)
+	for _, answer := range form.AnswerList {
+		if _, ok := answers[answer.QuestionIndex]; !ok {
+			answers[answer.QuestionIndex] = answer.Answers
+		}
+	}
+	return answers
+}
+
+// visibleQuestions tells for every question of the form whether it is shown
+// with these answers. Conditions only look at earlier questions, and the
+// answer to a hidden question counts as no answer.
+func visibleQuestions(post models.Post, answers map[int][]string) []bool {
+	visible := make([]bool, len(post.FormQuestions))
+	sections := make(map[int]bool)
+
+	holds := func(conditions []models.ShowIfCondition, before int) bool {
+		for _, condition := range conditions {
+			index := condition.QuestionIndex
+			var answer []string
+			if index >= 0 && index < before && visible[index] {
+				answer = answers[index]
+			}
+			if !showIfHolds(condition, answer) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i, question := range post.FormQuestions {
+		shown, ok := sections[question.Section]
+		if !ok {
+			shown = true
+			if question.Section >= 0 && question.Section < len(post.FormSections) {
+				shown = holds(post.FormSections[question.Section].ShowIf, i)
+			}
+			sections[question.Section] = shown
+		}
+		visible[i] = shown && holds(question.ShowIf, i)
+	}
+	return visible
+}
+
+func showIfHolds(condition models.ShowIfCondition, answer []string) bool {
+	var given []string
+	for _, value := range answer {
+		if value = strings.TrimSpace(value); value != "" {
+			given = append(given, value)
+		}
+	}
+
+	equals := len(given) == 1 && given[0] == condition.Value
+	switch condition.Operator {
+	case models.ShowIfEquals:
+		return equals
+	case models.ShowIfNotEquals:
+		return !equals
+	case models.ShowIfIncludes:
+		return containsString(given, condition.Value)
+	case models.ShowIfAnswered:
+		return len(given) > 0
+	}
+	return false
+}
+
+// visibleAnswers drops the answers to questions hidden by the form rules, so
+// that branches the student did not take are not stored
+func visibleAnswers(post models.Post, form models.AForm) []models.AQuestion {
+	visible := visibleQuestions(post, formAnswerMap(form))
+	answers := make([]models.AQuestion, 0, len(form.AnswerList))
+	for _, answer := range form.AnswerList {
+		if answer.QuestionIndex >= 0 && answer.QuestionIndex < len(visible) && visible[answer.QuestionIndex] {
+			answers = append(answers, answer)
+		}
+	}
+	return answers
+}
+
+// validateFormQuestions checks the sections, constraints and conditions of
+// a form post when it is saved
+func validateFormQuestions(post models.Post) string {
+	for s, section := range post.FormSections {
+		for _, condition := range section.ShowIf {
+			if msg := validateShowIf(condition); msg != "" {
+				return fmt.Sprintf("section %d: %s", s, msg)
+			}
+			index := condition.QuestionIndex
+			if index < 0 || index >= len(post.FormQuestions) || post.FormQuestions[index].Section >= s {
+				return fmt.Sprintf("section %d: conditions must refer to a question of an earlier section", s)
+			}
+		}
+	}
+
+	for i, question := range post.FormQuestions {
+		sections := len(post.FormSections)
+		if question.Section < 0 || (question.Section > 0 && question.Section >= sections) {
+			return fmt.Sprintf("question %d: section does not exist", i)
+		}
+		if i > 0 && question.Section < post.FormQuestions[i-1].Section {
+			return fmt.Sprintf("question %d: questions must be in the order of their sections", i)
+		}
+
+		for _, condition := range question.ShowIf {
+			if msg := validateShowIf(condition); msg != "" {
+				return fmt.Sprintf("question %d: %s", i, msg)
+			}
+			if condition.QuestionIndex < 0 || condition.QuestionIndex >= i {
+				return fmt.Sprintf("question %d: conditions must refer to an earlier question", i)
+			}
+		}
+
+		if question.Min != nil && question.Max != nil && *question.Min > *question.Max {
+			return fmt.Sprintf("question %d: min must not be greater than max", i)
+		}
+		if kind := questionKind(question); kind != questionNumeric && question.Min != nil && *question.Min < 0 {
+			return fmt.Sprintf("question %d: min must not be negative", i)
+		}
+		if question.MaxFileSize < 0 {
+			return fmt.Sprintf("question %d: maxFileSize must not be negative", i)
+		}
+	}
+	return ""
+}
+
+func validateShowIf(condition models.ShowIfCondition) string {
+	switch condition.Operator {
+	case models.ShowIfEquals, models.ShowIfNotEquals, models.ShowIfIncludes:
+		if condition.Value == "" {
+			return fmt.Sprintf("operator %q needs a value", condition.Operator)
+		}
+	case models.ShowIfAnswered:
+	default:
+		return fmt.Sprintf("unknown operator %q", condition.Operator)
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+func TestShowIfHolds(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition models.ShowIfCondition
+		answer    []string
+		want      bool
+	}{
+		{"equals matches", models.ShowIfCondition{Operator: models.ShowIfEquals, Value: "Yes"}, []string{" Yes "}, true},
+		{"equals other value", models.ShowIfCondition{Operator: models.ShowIfEquals, Value: "Yes"}, []string{"No"}, false},
+		{"equals several values", models.ShowIfCondition{Operator: models.ShowIfEquals, Value: "Yes"}, []string{"Yes", "No"}, false},
+		{"not equals without answer", models.ShowIfCondition{Operator: models.ShowIfNotEquals, Value: "Yes"}, nil, true},
+		{"not equals same value", models.ShowIfCondition{Operator: models.ShowIfNotEquals, Value: "Yes"}, []string{"Yes"}, false},
+		{"includes selected option", models.ShowIfCondition{Operator: models.ShowIfIncludes, Value: "B"}, []string{"A", "B"}, true},
+		{"includes missing option", models.ShowIfCondition{Operator: models.ShowIfIncludes, Value: "C"}, []string{"A", "B"}, false},
+		{"answered", models.ShowIfCondition{Operator: models.ShowIfAnswered}, []string{"x"}, true},
+		{"answered with blanks only", models.ShowIfCondition{Operator: models.ShowIfAnswered}, []string{" ", ""}, false},
+		{"unknown operator", models.ShowIfCondition{Operator: "matches", Value: "x"}, []string{"x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := showIfHolds(tt.condition, tt.answer); got != tt.want {
+				t.Errorf("showIfHolds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleQuestions(t *testing.T) {
+	post := models.Post{
+		FormSections: []models.FormSection{
+			{Title: "About you"},
+			{Title: "Transport", ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "Yes"}}},
+		},
+		FormQuestions: []models.FormQuestion{
+			{Question: "Joining the trip?", InputType: models.InputRadio, Options: []string{"Yes", "No"}},
+			{Question: "Why not?", InputType: models.InputText, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "No"}}},
+			{Question: "Need a bus seat?", InputType: models.InputRadio, Options: []string{"Yes", "No"}, Section: 1},
+			{Question: "Pick up point", InputType: models.InputText, Section: 1, ShowIf: []models.ShowIfCondition{{QuestionIndex: 2, Operator: models.ShowIfEquals, Value: "Yes"}}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		answers map[int][]string
+		want    []bool
+	}{
+		{"nothing answered", map[int][]string{}, []bool{true, false, false, false}},
+		{"not joining", map[int][]string{0: {"No"}}, []bool{true, true, false, false}},
+		{"joining", map[int][]string{0: {"Yes"}}, []bool{true, false, true, false}},
+		{"joining by bus", map[int][]string{0: {"Yes"}, 2: {"Yes"}}, []bool{true, false, true, true}},
+		{"answer of a hidden section ignored", map[int][]string{0: {"No"}, 2: {"Yes"}}, []bool{true, true, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleQuestions(post, tt.answers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("visibleQuestions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleAnswers(t *testing.T) {
+	post := models.Post{FormQuestions: []models.FormQuestion{
+		{InputType: models.InputRadio, Options: []string{"Yes", "No"}},
+		{InputType: models.InputText, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "No"}}},
+	}}
+	form := models.AForm{AnswerList: []models.AQuestion{
+		{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"Yes"}},
+		{QuestionIndex: 1, InputType: models.InputText, Answers: []string{"left over"}},
+		{QuestionIndex: 5, InputType: models.InputText, Answers: []string{"unknown"}},
+	}}
+
+	got := visibleAnswers(post, form)
+	want := []models.AQuestion{{QuestionIndex: 0, InputType: models.InputRadio, Answers: []string{"Yes"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("visibleAnswers() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateFormQuestions(t *testing.T) {
+	min, max := 5.0, 2.0
+	negative := -1.0
+
+	tests := []struct {
+		name string
+		post models.Post
+		want string
+	}{
+		{
+			name: "valid form",
+			post: models.Post{
+				FormSections: []models.FormSection{{}, {ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfAnswered}}}},
+				FormQuestions: []models.FormQuestion{
+					{InputType: models.InputText},
+					{InputType: models.InputText, Section: 1, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "x"}}},
+				},
+			},
+			want: "",
+		},
+		{
+			name: "section condition on a later question",
+			post: models.Post{
+				FormSections:  []models.FormSection{{}, {ShowIf: []models.ShowIfCondition{{QuestionIndex: 1, Operator: models.ShowIfAnswered}}}},
+				FormQuestions: []models.FormQuestion{{InputType: models.InputText}, {InputType: models.InputText, Section: 1}},
+			},
+			want: "section 1: conditions must refer to a question of an earlier section",
+		},
+		{
+			name: "question condition on itself",
+			post: models.Post{FormQuestions: []models.FormQuestion{
+				{InputType: models.InputText, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfAnswered}}},
+			}},
+			want: "question 0: conditions must refer to an earlier question",
+		},
+		{
+			name: "condition without value",
+			post: models.Post{FormQuestions: []models.FormQuestion{
+				{InputType: models.InputText},
+				{InputType: models.InputText, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals}}},
+			}},
+			want: `question 1: operator "equals" needs a value`,
+		},
+		{
+			name: "unknown operator",
+			post: models.Post{FormQuestions: []models.FormQuestion{
+				{InputType: models.InputText},
+				{InputType: models.InputText, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: "like"}}},
+			}},
+			want: `question 1: unknown operator "like"`,
+		},
+		{
+			name: "missing section",
+			post: models.Post{FormQuestions: []models.FormQuestion{{InputType: models.InputText, Section: 2}}},
+			want: "question 0: section does not exist",
+		},
+		{
+			name: "sections out of order",
+			post: models.Post{
+				FormSections:  []models.FormSection{{}, {}},
+				FormQuestions: []models.FormQuestion{{InputType: models.InputText, Section: 1}, {InputType: models.InputText}},
+			},
+			want: "question 1: questions must be in the order of their sections",
+		},
+		{
+			name: "min above max",
+			post: models.Post{FormQuestions: []models.FormQuestion{{InputType: models.InputNumber, Min: &min, Max: &max}}},
+			want: "question 0: min must not be greater than max",
+		},
+		{
+			name: "negative text length",
+			post: models.Post{FormQuestions: []models.FormQuestion{{InputType: models.InputText, Min: &negative}}},
+			want: "question 0: min must not be negative",
+		},
+		{
+			name: "negative number allowed",
+			post: models.Post{FormQuestions: []models.FormQuestion{{InputType: models.InputNumber, Min: &negative}}},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateFormQuestions(tt.post); got != tt.want {
+				t.Errorf("validateFormQuestions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+const (
+	numericHistogramBins = 10
+	topTermsLimit        = 10
+)
+
+// Words too common to say anything about a free text answer
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "was": true, "were": true, "this": true, "that": true, "with": true,
+	"have": true, "has": true, "had": true, "from": true, "they": true, "there": true,
+	"their": true, "what": true, "when": true, "which": true, "would": true, "could": true,
+	"should": true, "will": true, "very": true, "can": true, "all": true, "too": true,
+	"its": true, "our": true, "about": true, "more": true, "some": true, "also": true,
+}
+
+type optionStat struct {
+	Option  string  `json:"option"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"` // Of the respondents of the question
+}
+
+type histogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+type numericStats struct {
+	Mean      float64        `json:"mean"`
+	Median    float64        `json:"median"`
+	Min       float64        `json:"min"`
+	Max       float64        `json:"max"`
+	Histogram []histogramBin `json:"histogram"`
+}
+
+type termCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+type textStats struct {
+	TopTerms []termCount `json:"topTerms"`
+}
+
+// questionStats aggregates the answers of one form question, only the part
+// for its kind of question is set
+type questionStats struct {
+	QuestionIndex int           `json:"questionIndex"`
+	Question      string        `json:"question"`
+	InputType     string        `json:"inputType"`
+	Responses     int           `json:"responses"`
+	Options       []optionStat  `json:"options,omitempty"`
+	Numeric       *numericStats `json:"numeric,omitempty"`
+	Text          *textStats    `json:"text,omitempty"`
+}
+
+type responseRate struct {
+	Respondents  int     `json:"respondents"`
+	Participants int     `json:"participants"` // Members the form is shown to
+	Rate         float64 `json:"rate"`         // Percent, 0 when the form is shown to no one
+}
+
+// formStatistics computes the statistics of every question of the form
+func formStatistics(post models.Post, answers []models.AForm) []questionStats {
+	byQuestion := make([][][]string, len(post.FormQuestions))
+	for _, answer := range answers {
+		for _, question := range answer.AnswerList {
+			index := question.QuestionIndex
+			if index < 0 || index >= len(byQuestion) || !hasAnswer(question.Answers) {
+				continue
+			}
+			byQuestion[index] = append(byQuestion[index], question.Answers)
+		}
+	}
+
+	stats := make([]questionStats, 0, len(post.FormQuestions))
+	for i, question := range post.FormQuestions {
+		stat := questionStats{
+			QuestionIndex: i,
+			Question:      question.Question,
+			InputType:     question.InputType,
+			Responses:     len(byQuestion[i]),
+		}
+		switch questionKind(question) {
+		case questionSingleChoice, questionMultiChoice:
+			stat.Options = choiceStatistics(question, byQuestion[i])
+		case questionNumeric:
+			stat.Numeric = numericStatistics(question, byQuestion[i])
+		case questionText:
+			stat.Text = &textStats{TopTerms: topTerms(byQuestion[i], topTermsLimit)}
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func hasAnswer(answers []string) bool {
+	for _, answer := range answers {
+		if strings.TrimSpace(answer) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func choiceStatistics(question models.FormQuestion, responses [][]string) []optionStat {
+	counts := make(map[string]int)
+	for _, answers := range responses {
+		for _, option := range validChoices(question.Options, answers) {
+			counts[option]++
+		}
+	}
+
+	stats := make([]optionStat, 0, len(question.Options))
+	for _, option := range question.Options {
+		stats = append(stats, optionStat{Option: option, Count: counts[option], Percent: percent(counts[option], len(responses))})
+	}
+	return stats
+}
+
+// numericStatistics summarises number and rating answers. Ratings get a bin
+// per whole value, other numbers ten bins of equal width. NaN and infinite
+// answers are left out, and no step overflows for values near the float
+// limits.
+func numericStatistics(question models.FormQuestion, responses [][]string) *numericStats {
+	var values []float64
+	for _, answers := range responses {
+		for _, answer := range answers {
+			value, err := strconv.ParseFloat(strings.TrimSpace(answer), 64)
+			if err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				values = append(values, value)
+			}
+		}
+	}
+
+	stats := &numericStats{Histogram: []histogramBin{}}
+	if len(values) == 0 {
+		return stats
+	}
+	sort.Float64s(values)
+
+	// A running mean, a sum of large values would overflow
+	mean := 0.0
+	for i, value := range values {
+		mean += value/float64(i+1) - mean/float64(i+1)
+	}
+	stats.Mean = round2(mean)
+	stats.Min = values[0]
+	stats.Max = values[len(values)-1]
+	if middle := len(values) / 2; len(values)%2 == 1 {
+		stats.Median = values[middle]
+	} else {
+		stats.Median = round2(values[middle-1]/2 + values[middle]/2)
+	}
+
+	if question.InputType == models.InputRating && stats.Max-stats.Min < 100 {
+		for value := math.Floor(stats.Min); value <= stats.Max; value++ {
+			stats.Histogram = append(stats.Histogram, histogramBin{From: value, To: value})
+		}
+		for _, value := range values {
+			if bin := int(math.Floor(value) - math.Floor(stats.Min)); bin >= 0 && bin < len(stats.Histogram) {
+				stats.Histogram[bin].Count++
+			}
+		}
+		return stats
+	}
+
+	if stats.Min == stats.Max {
+		stats.Histogram = append(stats.Histogram, histogramBin{From: stats.Min, To: stats.Max, Count: len(values)})
+		return stats
+	}
+	width := stats.Max/numericHistogramBins - stats.Min/numericHistogramBins
+	// Bin edges are weighted between min and max, which cannot overflow
+	edge := func(i int) float64 {
+		t := float64(i) / numericHistogramBins
+		return round2(stats.Min*(1-t) + stats.Max*t)
+	}
+	for i := 0; i < numericHistogramBins; i++ {
+		stats.Histogram = append(stats.Histogram, histogramBin{From: edge(i), To: edge(i + 1)})
+	}
+	for _, value := range values {
+		// The difference may overflow to +Inf, it then lands in the last bin
+		position := (value - stats.Min) / width
+		bin := numericHistogramBins - 1 // The maximum belongs to the last bin
+		if position < numericHistogramBins {
+			bin = int(math.Max(position, 0))
+		}
+		stats.Histogram[bin].Count++
+	}
+	return stats
+}
+
+// topTerms counts the words of free text answers, each answer counting a
+// word once. Thai and other scripts written without spaces between words
+// cannot be split without a dictionary, such answers count as one term.
+func topTerms(responses [][]string, limit int) []termCount {
+	counts := make(map[string]int)
+	for _, answers := range responses {
+		seen := make(map[string]bool)
+		for _, answer := range answers {
+			words := strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r)
+			})
+			if unspacedScript(answer) {
+				words = []string{strings.Join(strings.Fields(strings.ToLower(answer)), " ")}
+			}
+			for _, word := range words {
+				if len([]rune(word)) < 3 || stopWords[word] || seen[word] {
+					continue
+				}
+				seen[word] = true
+				counts[word]++
+			}
+		}
+	}
+
+	terms := make([]termCount, 0, len(counts))
+	for term, count := range counts {
+		terms = append(terms, termCount{Term: term, Count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+// unspacedScript reports whether the text uses a script that does not put
+// spaces between words
+func unspacedScript(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+			return true
+		}
+	}
+	return false
+}
+
+// formResponseRate compares the respondents with the members the form is
+// shown to. Answers of anyone else, such as staff outside its audience, are
+// not counted, so the rate stays within the audience.
+func formResponseRate(ctx context.Context, post models.Post, answers []models.AForm) (responseRate, error) {
+	var rate responseRate
+
+	event, err := findPostEvent(ctx, post.ID)
+	if err != nil {
+		return rate, err
+	}
+	audience := make(map[string]bool)
+	for _, studentID := range postAudience(event, post) {
+		audience[studentID] = true
+	}
+	for _, answer := range answers {
+		if audience[answer.StudentID] {
+			rate.Respondents++
+		}
+	}
+	rate.Participants = len(audience)
+	rate.Rate = percent(rate.Respondents, rate.Participants)
+	return rate, nil
+}
+
+func percent(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(count) * 100 / float64(total))
+}
+
+func round2(value float64) float64 {
+	// Beyond this there are no hundredths to round, and value*100 could overflow
+	if math.Abs(value) >= 1<<52 {
+		return value
+	}
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+func TestChoiceStatistics(t *testing.T) {
+	question := models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}}
+	responses := [][]string{{"A", "B"}, {"B"}, {"B", "B", "D"}, {"C"}}
+
+	got := choiceStatistics(question, responses)
+	want := []optionStat{
+		{Option: "A", Count: 1, Percent: 25},
+		{Option: "B", Count: 3, Percent: 75},
+		{Option: "C", Count: 1, Percent: 25},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("choiceStatistics() = %v, want %v", got, want)
+	}
+}
+
+func TestNumericStatistics(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputType string
+		responses [][]string
+		mean      float64
+		median    float64
+		min       float64
+		max       float64
+		bins      int
+		counts    map[int]int // Count of some bins by index
+	}{
+		{
+			name:      "numbers",
+			inputType: models.InputNumber,
+			responses: [][]string{{"0"}, {"10"}, {" 5 "}, {"2.5"}},
+			mean:      4.38, median: 3.75, min: 0, max: 10,
+			bins:   numericHistogramBins,
+			counts: map[int]int{0: 1, 2: 1, 5: 1, 9: 1},
+		},
+		{
+			name:      "ratings get a bin per value",
+			inputType: models.InputRating,
+			responses: [][]string{{"1"}, {"3"}, {"3"}, {"5"}},
+			mean:      3, median: 3, min: 1, max: 5,
+			bins:   5,
+			counts: map[int]int{0: 1, 2: 2, 4: 1},
+		},
+		{
+			name:      "same value",
+			inputType: models.InputNumber,
+			responses: [][]string{{"7"}, {"7"}},
+			mean:      7, median: 7, min: 7, max: 7,
+			bins:   1,
+			counts: map[int]int{0: 2},
+		},
+		{
+			name:      "non-finite values are skipped",
+			inputType: models.InputRating,
+			responses: [][]string{{"NaN"}, {"Inf"}, {"-Inf"}, {"2"}, {"x"}},
+			mean:      2, median: 2, min: 2, max: 2,
+			bins:   1,
+			counts: map[int]int{0: 1},
+		},
+		{
+			name:      "values near the float limit",
+			inputType: models.InputNumber,
+			responses: [][]string{{"1e308"}, {"1e308"}, {"-1e308"}},
+			mean:      1e308 / 3, median: 1e308, min: -1e308, max: 1e308,
+			bins:   numericHistogramBins,
+			counts: map[int]int{0: 1, 9: 2},
+		},
+		{
+			name:      "no values",
+			inputType: models.InputNumber,
+			responses: [][]string{{"none"}},
+			bins:      0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := numericStatistics(models.FormQuestion{InputType: tt.inputType}, tt.responses)
+			if _, err := json.Marshal(stats); err != nil {
+				t.Fatalf("statistics cannot be sent: %v", err)
+			}
+			if stats.Mean != tt.mean || stats.Median != tt.median || stats.Min != tt.min || stats.Max != tt.max {
+				t.Errorf("mean, median, min, max = %v, %v, %v, %v, want %v, %v, %v, %v",
+					stats.Mean, stats.Median, stats.Min, stats.Max, tt.mean, tt.median, tt.min, tt.max)
+			}
+			if len(stats.Histogram) != tt.bins {
+				t.Fatalf("%d bins, want %d", len(stats.Histogram), tt.bins)
+			}
+			for bin, count := range tt.counts {
+				if stats.Histogram[bin].Count != count {
+					t.Errorf("bin %d has %d values, want %d", bin, stats.Histogram[bin].Count, count)
+				}
+			}
+		})
+	}
+}
+
+func TestTopTerms(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses [][]string
+		limit     int
+		want      []termCount
+	}{
+		{
+			name:      "words counted once per answer",
+			responses: [][]string{{"Great food, great venue"}, {"The FOOD was cold"}, {"venue"}},
+			limit:     10,
+			want:      []termCount{{"food", 2}, {"venue", 2}, {"cold", 1}, {"great", 1}},
+		},
+		{
+			name:      "thai answers count whole",
+			responses: [][]string{{"อาหารอร่อยมาก"}, {" อาหารอร่อยมาก "}, {"สถานที่ ดี"}},
+			limit:     10,
+			want:      []termCount{{"อาหารอร่อยมาก", 2}, {"สถานที่ ดี", 1}},
+		},
+		{
+			name:      "limit",
+			responses: [][]string{{"alpha beta"}, {"beta gamma"}, {"gamma delta"}},
+			limit:     2,
+			want:      []termCount{{"beta", 2}, {"gamma", 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topTerms(tt.responses, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("topTerms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormStatistics(t *testing.T) {
+	post := models.Post{FormQuestions: []models.FormQuestion{
+		{Question: "Pick", InputType: models.InputRadio, Options: []string{"A", "B"}},
+		{Question: "Rate", InputType: models.InputRating},
+		{Question: "Comment", InputType: models.InputText},
+	}}
+	answers := []models.AForm{
+		{AnswerList: []models.AQuestion{{QuestionIndex: 0, Answers: []string{"A"}}, {QuestionIndex: 1, Answers: []string{"4"}}}},
+		{AnswerList: []models.AQuestion{{QuestionIndex: 0, Answers: []string{" "}}, {QuestionIndex: 2, Answers: []string{"nice"}}, {QuestionIndex: 7, Answers: []string{"x"}}}},
+	}
+
+	stats := formStatistics(post, answers)
+	if len(stats) != 3 {
+		t.Fatalf("%d questions, want 3", len(stats))
+	}
+	responses := []int{stats[0].Responses, stats[1].Responses, stats[2].Responses}
+	if !reflect.DeepEqual(responses, []int{1, 1, 1}) {
+		t.Errorf("responses = %v, want [1 1 1]", responses)
+	}
+	if stats[0].Options == nil || stats[1].Numeric == nil || stats[2].Text == nil {
+		t.Errorf("statistics of the wrong kind: %+v", stats)
+	}
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index is one set of indexes of a collection. Required ones are relied on
+// for correctness rather than speed, the server must not run without them.
+type index struct {
+	name       string
+	collection *mongo.Collection
+	models     []mongo.IndexModel
+	prepare    func(ctx context.Context) error // Makes existing documents fit the index
+	required   bool
+}
+
+// CreateIndexes makes sure the indexes the controllers rely on exist. A
+// failing index is logged and the others are still created, the error is
+// only returned when a required one could not be built.
+func CreateIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	unique := options.Index().SetUnique(true)
+	indexes := []index{
+		{name: "check-ins", collection: checkInCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "eventID", Value: 1}, {Key: "studentID", Value: 1}}, Options: unique},
+		}},
+		{name: "answers", collection: transactionCollection, prepare: removeDuplicateAnswers, required: true, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "studentID", Value: 1}}, Options: unique},
+		}},
+		{name: "vote participation", collection: participationCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "studentID", Value: 1}}, Options: unique},
+		}},
+		{name: "ballots", collection: ballotCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "receiptHash", Value: 1}}},
+		}},
+		{name: "pending ballots", collection: pendingBallotCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "receiptHash", Value: 1}}},
+			{Keys: bson.D{{Key: "claim", Value: 1}}},
+		}},
+		{name: "media", collection: mediaCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "ownerID", Value: 1}, {Key: "kind", Value: 1}}},
+		}},
+		{name: "notifications", collection: notificationCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "studentID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		}},
+		{name: "comments", collection: commentCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "createdAt", Value: 1}}},
+		}},
+		{name: "task progress", collection: taskProgressCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "studentID", Value: 1}}, Options: unique},
+		}},
+		{name: "post history", collection: postHistoryCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "revision", Value: 1}}, Options: unique},
+		}},
+		{name: "attachments", collection: attachmentCollection, models: []mongo.IndexModel{
+			{Keys: bson.D{{Key: "postID", Value: 1}, {Key: "uploadedAt", Value: 1}}},
+			{Keys: bson.D{{Key: "eventID", Value: 1}}},
+		}},
+	}
+
+	var failed error
+	for _, idx := range indexes {
+		err := createIndex(ctx, idx)
+		if err == nil {
+			continue
+		}
+		if idx.required {
+			failed = fmt.Errorf("%s index: %w", idx.name, err)
+		} else {
+			log.Printf("Failed to create the %s index: %v", idx.name, err)
+		}
+	}
+	return failed
+}
+
+func createIndex(ctx context.Context, idx index) error {
+	if idx.prepare != nil {
+		if err := idx.prepare(ctx); err != nil {
+			return err
+		}
+	}
+	_, err := idx.collection.Indexes().CreateMany(ctx, idx.models)
+	return err
+}
+
+// removeDuplicateAnswers keeps only the latest answer of every student per
+// post, so the unique index can be built over answers from before it existed.
+// The extra copies are moved to the answer history.
+func removeDuplicateAnswers(ctx context.Context) error {
+	pipeline := mongo.Pipeline{
+		// Newest first, answers from before updatedAt existed by creation
+		{{Key: "$sort", Value: bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "postID", Value: "$postID"}, {Key: "studentID", Value: "$studentID"}}},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}
+
+	cursor, err := transactionCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, group := range groups {
+		for _, id := range group.IDs[1:] {
+			raw, err := transactionCollection.FindOne(ctx, bson.M{"_id": id}).Raw()
+			if err != nil {
+				return err
+			}
+			answer, err := storedAnswerFromRaw(raw)
+			if err != nil {
+				return err
+			}
+			if err := archiveAnswer(ctx, answer, models.AnswerActionDeduplicate); err != nil {
+				return err
+			}
+			if _, err := transactionCollection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+				return err
+			}
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Moved %d duplicate answers to the answer history", removed)
+	}
+	return nil
+}
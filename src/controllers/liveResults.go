@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Live results are pushed at most this often, a busy poll is coalesced
+const (
+	liveResultsInterval  = time.Second
+	liveResultsHeartbeat = 25 * time.Second
+	liveResultsReconnect = 5 * time.Second
+)
+
+// resultsBroker tells the open result streams of a post that its answers changed
+type resultsBroker struct {
+	mu          sync.Mutex
+	subscribers map[primitive.ObjectID]map[chan struct{}]struct{}
+}
+
+var liveResults = &resultsBroker{subscribers: make(map[primitive.ObjectID]map[chan struct{}]struct{})}
+
+func (b *resultsBroker) subscribe(postID primitive.ObjectID) chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if b.subscribers[postID] == nil {
+		b.subscribers[postID] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[postID][ch] = struct{}{}
+	return ch
+}
+
+func (b *resultsBroker) unsubscribe(postID primitive.ObjectID, ch chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers[postID], ch)
+	if len(b.subscribers[postID]) == 0 {
+		delete(b.subscribers, postID)
+	}
+}
+
+func (b *resultsBroker) publish(postID primitive.ObjectID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[postID] {
+		// A pending notification already covers this change
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *resultsBroker) publishAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// notifyAnswerChange is called after an answer of the post is written. A
+// change stream may report the same write, both end up in one update.
+func notifyAnswerChange(postID primitive.ObjectID) {
+	liveResults.publish(postID)
+}
+
+// WatchAnswerChanges also feeds the live results from change streams on the
+// answer collections, so answers written by other instances are seen. On a
+// standalone server there are no change streams and only the in-process
+// notifications are used.
+func WatchAnswerChanges() {
+	ctx := context.Background()
+
+	// A standalone server refuses the first stream, nothing is watched then
+	var streams []*mongo.ChangeStream
+	for _, collection := range []*mongo.Collection{transactionCollection, ballotCollection} {
+		stream, err := openAnswerStream(ctx, collection, nil)
+		if err != nil {
+			log.Printf("Change streams unavailable, live results use in-process notifications: %v", err)
+			for _, stream := range streams {
+				stream.Close(ctx)
+			}
+			return
+		}
+		streams = append(streams, stream)
+	}
+
+	var wg sync.WaitGroup
+	for i, collection := range []*mongo.Collection{transactionCollection, ballotCollection} {
+		wg.Add(1)
+		go func(collection *mongo.Collection, stream *mongo.ChangeStream) {
+			defer wg.Done()
+			watchAnswerStream(ctx, collection, stream)
+		}(collection, streams[i])
+	}
+	wg.Wait()
+}
+
+func openAnswerStream(ctx context.Context, collection *mongo.Collection, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"operationType":       bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+			"fullDocument.status": bson.M{"$ne": models.AnswerStatusDraft}, // Drafts do not count in the results
+		}}},
+	}
+	// Deletes only carry the _id, their post is known when pre-images are enabled
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+	return collection.Watch(ctx, pipeline, opts)
+}
+
+// watchAnswerStream publishes the changes of a stream, reopening it where it
+// stopped whenever it closes
+func watchAnswerStream(ctx context.Context, collection *mongo.Collection, stream *mongo.ChangeStream) {
+	var resumeToken bson.Raw
+	for {
+		for stream.Next(ctx) {
+			var change struct {
+				FullDocument struct {
+					PostID primitive.ObjectID `bson:"postID"`
+				} `bson:"fullDocument"`
+				FullDocumentBeforeChange struct {
+					PostID primitive.ObjectID `bson:"postID"`
+				} `bson:"fullDocumentBeforeChange"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				continue
+			}
+			postID := change.FullDocument.PostID
+			if postID.IsZero() {
+				postID = change.FullDocumentBeforeChange.PostID
+			}
+			if !postID.IsZero() {
+				liveResults.publish(postID)
+			}
+		}
+		log.Printf("Change stream on %s closed, reopening: %v", collection.Name(), stream.Err())
+		if token := stream.ResumeToken(); token != nil {
+			resumeToken = token
+		}
+		stream.Close(ctx)
+
+		for {
+			time.Sleep(liveResultsReconnect)
+			var err error
+			stream, err = openAnswerStream(ctx, collection, resumeToken)
+			if err == nil {
+				break
+			}
+			// Too old to resume from, the changes in between are missed
+			log.Printf("Error reopening change stream on %s: %v", collection.Name(), err)
+			resumeToken = nil
+		}
+		// Results may have changed while the stream was down
+		liveResults.publishAll()
+	}
+}
+
+// StreamSummaryAnswer pushes the summary of a post as Server-Sent Events,
+// once when connected and again whenever its answers change
+func StreamSummaryAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID"})
+			return
+		}
+
+		if _, _, ok := loadSummaryPost(c, ctx, postID); !ok {
+			return
+		}
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		updates := liveResults.subscribe(postID)
+		defer liveResults.unsubscribe(postID, updates)
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Accel-Buffering", "no")
+
+		heartbeat := time.NewTicker(liveResultsHeartbeat)
+		defer heartbeat.Stop()
+
+		// The post and the access to its results may change while streaming
+		send := func() bool {
+			post, withRespondents, err := summaryAccess(ctx, postID, userID, access.(int))
+			if err != nil {
+				c.SSEvent("error", gin.H{"error": err.Error()})
+				return false
+			}
+			summary, err := buildSummary(ctx, post, withRespondents)
+			if err != nil {
+				c.SSEvent("error", gin.H{"error": err.Error()})
+				return false
+			}
+			c.SSEvent("summary", summary)
+			return true
+		}
+
+		first := true
+		c.Stream(func(w io.Writer) bool {
+			if first {
+				first = false
+				return send()
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().UTC())
+				return true
+			case <-updates:
+				// Let a burst of answers settle into one update
+				select {
+				case <-ctx.Done():
+					return false
+				case <-time.After(liveResultsInterval):
+				}
+				select {
+				case <-updates:
+				default:
+				}
+				return send()
+			}
+		})
+	}
+}
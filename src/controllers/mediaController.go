@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+	"github.com/encall/cpeevent-backend/src/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var mediaCollection *mongo.Collection = database.OpenCollection(database.Client, "media")
+
+// mediaPath prefixes the ID of a media item in the URLs stored on documents
+const mediaPath = "/api/v1/media/"
+
+// maxMediaSize is the largest image accepted for profiles, icons and posters
+var maxMediaSize = mediaSizeLimit()
+
+// thumbnailSides is the longest side of the thumbnail of each kind of media
+var thumbnailSides = map[string]int{
+	models.MediaProfile: 256,
+	models.MediaIcon:    256,
+	models.MediaPoster:  480,
+}
+
+func mediaSizeLimit() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("MEDIA_MAX_SIZE_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = 5
+	}
+	return int64(megabytes) << 20
+}
+
+func mediaURL(id primitive.ObjectID) string {
+	return mediaPath + id.Hex()
+}
+
+// mediaIDFromURL finds the media item behind a URL stored on a document
+func mediaIDFromURL(url *string) (primitive.ObjectID, bool) {
+	if url == nil {
+		return primitive.NilObjectID, false
+	}
+	hexID, ok := strings.CutPrefix(*url, mediaPath)
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	return id, err == nil
+}
+
+// saveMedia validates an image, stores it with its thumbnail and records it
+func saveMedia(ctx context.Context, kind string, ownerID string, data []byte) (models.Media, error) {
+	img, format, err := helper.DecodeImage(data)
+	if err != nil {
+		return models.Media{}, err
+	}
+	thumb, thumbType, err := helper.EncodeImage(helper.Thumbnail(img, thumbnailSides[kind]), format)
+	if err != nil {
+		return models.Media{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	media := models.Media{
+		ID:          primitive.NewObjectID(),
+		Kind:        kind,
+		OwnerID:     ownerID,
+		ContentType: helper.ImageContentType(format),
+		ThumbType:   thumbType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Size:        int64(len(data)),
+		ThumbSize:   int64(len(thumb)),
+		ETag:        hex.EncodeToString(sum[:16]),
+		CreatedAt:   time.Now().UTC(),
+	}
+	media.Key = "media/" + media.ID.Hex()
+	media.ThumbKey = media.Key + "-thumb"
+
+	if err := storage.Backend.Save(ctx, media.Key, bytes.NewReader(data)); err != nil {
+		return models.Media{}, err
+	}
+	if err := storage.Backend.Save(ctx, media.ThumbKey, bytes.NewReader(thumb)); err != nil {
+		storage.Backend.Delete(ctx, media.Key)
+		return models.Media{}, err
+	}
+	if _, err := mediaCollection.InsertOne(ctx, media); err != nil {
+		storage.Backend.Delete(ctx, media.Key)
+		storage.Backend.Delete(ctx, media.ThumbKey)
+		return models.Media{}, err
+	}
+	return media, nil
+}
+
+// saveRawMedia stores an image that cannot be decoded as it is, without a
+// thumbnail, so migrating it loses nothing. It is served as an image only
+// when its bytes look like one.
+func saveRawMedia(ctx context.Context, kind string, ownerID string, data []byte) (models.Media, error) {
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = "application/octet-stream"
+	}
+
+	sum := sha256.Sum256(data)
+	media := models.Media{
+		ID:          primitive.NewObjectID(),
+		Kind:        kind,
+		OwnerID:     ownerID,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		ETag:        hex.EncodeToString(sum[:16]),
+		CreatedAt:   time.Now().UTC(),
+	}
+	media.Key = "media/" + media.ID.Hex()
+
+	if err := storage.Backend.Save(ctx, media.Key, bytes.NewReader(data)); err != nil {
+		return models.Media{}, err
+	}
+	if _, err := mediaCollection.InsertOne(ctx, media); err != nil {
+		storage.Backend.Delete(ctx, media.Key)
+		return models.Media{}, err
+	}
+	return media, nil
+}
+
+// deleteMedia removes a media item and its files, missing items are ignored
+func deleteMedia(ctx context.Context, id primitive.ObjectID) error {
+	var media models.Media
+	err := mediaCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{media.Key, media.ThumbKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Backend.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	_, err = mediaCollection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// replaceMedia deletes the media item a document pointed to before it was
+// changed. The document is already updated, so a failure only leaves an
+// orphan behind.
+func replaceMedia(ctx context.Context, oldURL *string) {
+	if id, ok := mediaIDFromURL(oldURL); ok {
+		if err := deleteMedia(ctx, id); err != nil {
+			log.Printf("Error deleting media %s: %v", id.Hex(), err)
+		}
+	}
+}
+
+// readMediaUpload reads the image sent in a multipart field. It answers the
+// request itself and returns false when the upload is missing or unusable.
+func readMediaUpload(c *gin.Context, field string) ([]byte, bool) {
+	header, err := c.FormFile(field)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large", "maxFileSize": maxMediaSize})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": field + " is required"})
+		return nil, false
+	}
+	if header.Size > maxMediaSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large", "maxFileSize": maxMediaSize})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to open image"})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read image"})
+		return nil, false
+	}
+	return data, true
+}
+
+// mediaError answers a request whose image could not be saved
+func mediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, helper.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, helper.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error(), "maxPixels": helper.MaxImagePixels})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing image"})
+	}
+}
+
+// GetMedia serves an image, or its thumbnail with ?size=thumb. A media item
+// never changes, so clients and proxies may keep it for good.
+func GetMedia() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		mediaID, err := primitive.ObjectIDFromHex(c.Param("mediaID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mediaID format"})
+			return
+		}
+
+		var media models.Media
+		if err := mediaCollection.FindOne(ctx, bson.M{"_id": mediaID}).Decode(&media); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		key, contentType, size, etag := media.Key, media.ContentType, media.Size, media.ETag
+		switch c.DefaultQuery("size", "full") {
+		case "full":
+		case "thumb":
+			// Images kept as they were have no thumbnail, the original is sent
+			if media.ThumbKey != "" {
+				key, contentType, size, etag = media.ThumbKey, media.ThumbType, media.ThumbSize, media.ETag+"-thumb"
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be full or thumb"})
+			return
+		}
+		etag = `"` + etag + `"`
+
+		headers := map[string]string{
+			"Cache-Control":          "public, max-age=31536000, immutable",
+			"ETag":                   etag,
+			"X-Content-Type-Options": "nosniff",
+		}
+		if c.GetHeader("If-None-Match") == etag {
+			for name, value := range headers {
+				c.Header(name, value)
+			}
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		reader, err := storage.Backend.Open(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading image"})
+			return
+		}
+		defer reader.Close()
+
+		c.DataFromReader(http.StatusOK, size, contentType, reader, headers)
+	}
+}
+
+// UploadEventMedia replaces the icon or the poster of an event with the
+// image sent in the "file" field
+func UploadEventMedia() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		eventID, err := primitive.ObjectIDFromHex(c.Param("eventID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Event ID"})
+			return
+		}
+		kind := c.Param("kind")
+		if kind != models.MediaIcon && kind != models.MediaPoster {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be icon or poster"})
+			return
+		}
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if access.(int) < 3 && !getMembership(event, userID).IsPresident {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the president can change the event images"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMediaSize+1<<20)
+		data, ok := readMediaUpload(c, "file")
+		if !ok {
+			return
+		}
+
+		media, err := saveMedia(ctx, kind, eventID.Hex(), data)
+		if err != nil {
+			mediaError(c, err)
+			return
+		}
+
+		url := mediaURL(media.ID)
+		if _, err := eventCollection.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$set": bson.M{kind: url}}); err != nil {
+			deleteMedia(ctx, media.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if kind == models.MediaIcon {
+			replaceMedia(ctx, event.Icon)
+		} else {
+			replaceMedia(ctx, event.Poster)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"media":    media,
+			"url":      url,
+			"thumbUrl": url + "?size=thumb",
+		}})
+	}
+}
+
+// deleteEventMedia removes the icon and poster of an event
+func deleteEventMedia(ctx context.Context, eventID primitive.ObjectID) error {
+	cursor, err := mediaCollection.Find(ctx, bson.M{"ownerID": eventID.Hex(), "kind": bson.M{"$in": []string{models.MediaIcon, models.MediaPoster}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var items []models.Media
+	if err := cursor.All(ctx, &items); err != nil {
+		return err
+	}
+	for _, media := range items {
+		if err := deleteMedia(ctx, media.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDataURL decodes an image given inline as "data:image/...;base64,..."
+func parseDataURL(value string) ([]byte, bool) {
+	rest, ok := strings.CutPrefix(value, "data:image/")
+	if !ok {
+		return nil, false
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// moveInlineEventImages moves an icon or poster given as a data URL to the
+// media storage and points the event at it
+func moveInlineEventImages(ctx context.Context, event models.Event) error {
+	set := bson.M{}
+	for kind, value := range map[string]*string{models.MediaIcon: event.Icon, models.MediaPoster: event.Poster} {
+		if value == nil || !strings.HasPrefix(*value, "data:") {
+			continue
+		}
+		data, ok := parseDataURL(*value)
+		if !ok {
+			log.Printf("Event %s has an unreadable inline %s, leaving it as is", event.ID.Hex(), kind)
+			continue
+		}
+		media, err := saveMedia(ctx, kind, event.ID.Hex(), data)
+		if errors.Is(err, helper.ErrUnsupportedImage) || errors.Is(err, helper.ErrImageTooLarge) {
+			log.Printf("Event %s has an unusable inline %s: %v", event.ID.Hex(), kind, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		set[kind] = mediaURL(media.ID)
+	}
+
+	if len(set) == 0 {
+		return nil
+	}
+	_, err := eventCollection.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": set})
+	return err
+}
+
+// MigrateInlineImages moves the profile images kept as bytes in the users
+// and the icons and posters kept as data URLs in the events to the media
+// storage. It runs once for the whole deployment, on the instance that
+// claims it first.
+func MigrateInlineImages() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	const migration = "inline-images"
+	claimed, err := claimMigration(ctx, migration)
+	if err != nil || !claimed {
+		return err
+	}
+	err = migrateInlineImages(ctx)
+	if finishErr := finishMigration(ctx, migration, err); err == nil {
+		err = finishErr
+	}
+	return err
+}
+
+// migrateInlineImages does the work of MigrateInlineImages. Nothing is
+// deleted: profile images that cannot be decoded are stored as they are, and
+// such event images are left in the event.
+func migrateInlineImages(ctx context.Context) error {
+	opts := options.Find().SetProjection(bson.M{"studentID": 1, "imgProfile": 1})
+	cursor, err := userCollection.Find(ctx, bson.M{"imgProfile": bson.M{"$type": "binData"}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	profiles := 0
+	for cursor.Next(ctx) {
+		var user struct {
+			ID         primitive.ObjectID `bson:"_id"`
+			StudentID  string             `bson:"studentID"`
+			ImgProfile []byte             `bson:"imgProfile"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		media, err := saveMedia(ctx, models.MediaProfile, user.StudentID, user.ImgProfile)
+		if errors.Is(err, helper.ErrUnsupportedImage) || errors.Is(err, helper.ErrImageTooLarge) {
+			// Kept as it is, profiles must hold a URL to be read at all
+			log.Printf("User %s has an unusable inline profile image, storing it without a thumbnail: %v", user.StudentID, err)
+			media, err = saveRawMedia(ctx, models.MediaProfile, user.StudentID, user.ImgProfile)
+		}
+		if err != nil {
+			return err
+		}
+		update := bson.M{"$set": bson.M{"imgProfile": mediaURL(media.ID)}}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			return err
+		}
+		profiles++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	filter := bson.M{"$or": []bson.M{
+		{"icon": bson.M{"$regex": "^data:image/"}},
+		{"poster": bson.M{"$regex": "^data:image/"}},
+	}}
+	eventCursor, err := eventCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer eventCursor.Close(ctx)
+
+	events := 0
+	for eventCursor.Next(ctx) {
+		var event models.Event
+		if err := eventCursor.Decode(&event); err != nil {
+			return err
+		}
+		if err := moveInlineEventImages(ctx, event); err != nil {
+			return err
+		}
+		events++
+	}
+	if err := eventCursor.Err(); err != nil {
+		return err
+	}
+
+	if profiles > 0 || events > 0 {
+		log.Printf("Moved the inline images of %d users and %d events to the media storage", profiles, events)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationCollection records the data migrations run on the database, so
+// one instance of the deployment runs each of them, once
+var migrationCollection *mongo.Collection = database.OpenCollection(database.Client, "migrations")
+
+// migrationClaimTimeout is how long an instance may take to run a migration
+// before another one takes it over
+const migrationClaimTimeout = time.Hour
+
+// claimMigration reports whether the caller is to run the migration. It is
+// false when the migration is done or another instance is running it.
+func claimMigration(ctx context.Context, name string) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{"_id": name, "done": bson.M{"$ne": true}, "$or": bson.A{
+		bson.M{"claimedAt": bson.M{"$exists": false}},
+		bson.M{"claimedAt": bson.M{"$lt": now.Add(-migrationClaimTimeout)}},
+	}}
+	// A migration that cannot be claimed fails the upsert on its _id
+	_, err := migrationCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"claimedAt": now}}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// finishMigration records the outcome of a claimed migration. A failed one is
+// released, to be tried again on the next start.
+func finishMigration(ctx context.Context, name string, failed error) error {
+	update := bson.M{"$set": bson.M{"done": true, "doneAt": time.Now().UTC()}, "$unset": bson.M{"claimedAt": ""}}
+	if failed != nil {
+		update = bson.M{"$unset": bson.M{"claimedAt": ""}}
+	}
+	_, err := migrationCollection.UpdateOne(ctx, bson.M{"_id": name}, update)
+	return err
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var notificationCollection *mongo.Collection = database.OpenCollection(database.Client, "notifications")
+
+// At most this many notifications are listed at once
+const maxNotifications = 100
+
+// notifyStudents sends a copy of the notification to every student
+func notifyStudents(ctx context.Context, studentIDs []string, notification models.Notification) error {
+	if len(studentIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	docs := make([]interface{}, 0, len(studentIDs))
+	for _, studentID := range studentIDs {
+		doc := notification
+		doc.ID = primitive.NewObjectID()
+		doc.StudentID = studentID
+		doc.CreatedAt = now
+		doc.ReadAt = nil
+		docs = append(docs, doc)
+	}
+	_, err := notificationCollection.InsertMany(ctx, docs)
+	return err
+}
+
+// GetNotifications lists the notifications of the logged in student, newest
+// first. usage: ?unread=true&limit=50
+func GetNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxNotifications)))
+		if err != nil || limit <= 0 || limit > maxNotifications {
+			limit = maxNotifications
+		}
+
+		filter := bson.M{"studentID": userID}
+		if c.Query("unread") == "true" {
+			filter["readAt"] = nil
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit))
+		cursor, err := notificationCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		notifications := []models.Notification{}
+		if err := cursor.All(ctx, &notifications); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		unread, err := notificationCollection.CountDocuments(ctx, bson.M{"studentID": userID, "readAt": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": notifications, "unread": unread})
+	}
+}
+
+// MarkNotificationRead marks one notification of the logged in student as read
+func MarkNotificationRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		notificationID, err := primitive.ObjectIDFromHex(c.Param("notificationID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationID format"})
+			return
+		}
+
+		filter := bson.M{"_id": notificationID, "studentID": userID}
+		result, err := notificationCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"readAt": time.Now().UTC()}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "notification read"})
+	}
+}
+
+// MarkAllNotificationsRead marks every notification of the logged in student as read
+func MarkAllNotificationsRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		filter := bson.M{"studentID": userID, "readAt": nil}
+		result, err := notificationCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"readAt": time.Now().UTC()}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"read": result.ModifiedCount}})
+	}
+}
@@ -38,7 +38,7 @@ func DeleteAllPosts(eventID primitive.ObjectID) error {
 	// DeleteAllAnswers(event.PostList[0])
 
 	for _, postID := range event.PostList {
-		if err := DeleteAllAnswers(postID); err != nil {
+		if err := deletePostData(postID); err != nil {
 			log.Println("error deleting for postID: ", postID, err)
 			return err
 		}
@@ -55,6 +55,38 @@ func DeleteAllPosts(eventID primitive.ObjectID) error {
 
 }
 
+// deletePostData removes everything kept about a post besides the post
+// itself: its answers, comments, task progress, history and attachments
+func deletePostData(postID primitive.ObjectID) error {
+	if err := DeleteAllAnswers(postID); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if err := deleteComments(ctx, postID); err != nil {
+		log.Println("Error deleting comments:", err)
+		return err
+	}
+
+	if err := deleteTaskProgress(ctx, postID); err != nil {
+		log.Println("Error deleting task progress:", err)
+		return err
+	}
+
+	if err := deletePostHistory(ctx, postID); err != nil {
+		log.Println("Error deleting post history:", err)
+		return err
+	}
+
+	if err := deleteAttachments(ctx, postID); err != nil {
+		log.Println("Error deleting attachments:", err)
+		return err
+	}
+	return nil
+}
+
 func UpdatePost() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
@@ -66,41 +98,67 @@ func UpdatePost() gin.HandlerFunc {
 			return
 		}
 
-		updatePost := bson.M{
-			"assignTo":    post.AssignTo,
-			"public":      post.Public,
-			"title":       post.Title,
-			"description": post.Description,
-			"endDate":     post.EndDate,
+		userID, _ := c.Get("studentid")
+
+		if !validResultsVisibility(post.ResultsVisibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resultsVisibility"})
+			return
+		}
+
+		if post.GracePeriod < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gracePeriod must not be negative"})
+			return
 		}
 
-		switch post.Kind {
-		case "post":
-			updatePost["markdown"] = post.Markdown
-		case "vote":
-			updatePost["voteQuestions"] = post.VoteQuestions
-		case "form":
-			updatePost["formQuestions"] = post.FormQuestions
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post kind"})
+		if msg := validatePostSchedule(post); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 			return
 		}
 
 		objID, err := primitive.ObjectIDFromHex(post.PostID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid postID format"})
+			return
+		}
+
+		// Only the organizers of the event edit its posts
+		stored, event, ok := loadOrganizedPost(c, ctx, objID)
+		if !ok {
+			return
+		}
+		if post.Kind != stored.Kind {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The kind of a post cannot be changed"})
+			return
+		}
+
+		kind, err := lookupPostKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+		if msg := kind.validate(event, post); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		// The previous content is kept in the post history
+		updated, err := savePostEdit(ctx, stored, editablePostFields(kind, post), models.PostActionEdit, userID.(string), 0)
+		if err == errPostChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
 		}
-		result, err := postCollection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updatePost})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		if err := kind.edited(ctx, updated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		post.Revision = updated.Revision
+		post.QuestionsVersion = updated.QuestionsVersion
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": post})
 	}
 }
@@ -144,8 +202,7 @@ func DeletePost() gin.HandlerFunc {
 			return
 		}
 
-		_, err = transactionCollection.DeleteMany(ctx, bson.M{"postID": postObjID})
-		if err != nil {
+		if err := deletePostData(postObjID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting transactions"})
 			return
 		}
@@ -154,20 +211,21 @@ func DeletePost() gin.HandlerFunc {
 	}
 }
 
-func NewPost(post models.Post, timeUp bool) interface{} {
-	switch post.Kind {
-	case "post":
-		// Create and return a PPost
-		return models.PPost{Post: post, TimeUp: timeUp}
-	case "vote":
-		// Create and return a PVote with questions
-		return models.PVote{Post: post, Questions: post.VoteQuestions, TimeUp: timeUp}
-	case "form":
-		return models.PForm{Post: post, Questions: post.FormQuestions, TimeUp: timeUp}
-	default:
-		// Handle unknown post kinds, return nil or an error if needed
-		return nil
+func validResultsVisibility(visibility string) bool {
+	switch visibility {
+	case "", models.ResultsVisibleNever, models.ResultsVisibleDuring, models.ResultsVisibleAfterClose:
+		return true
 	}
+	return false
+}
+
+// NewPost returns the post as the type of its kind
+func NewPost(post models.Post, timeUp bool) (interface{}, error) {
+	kind, err := lookupPostKind(post.Kind)
+	if err != nil {
+		return nil, err
+	}
+	return kind.response(post, timeUp), nil
 }
 
 func CreateNewPost() gin.HandlerFunc {
@@ -202,6 +260,12 @@ func CreateNewPost() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Event not found"})
 			return
 		}
+		switch status := currentEventStatus(event); status {
+		case models.EventStatusFinished, models.EventStatusArchived:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot post to an event that has ended", "status": status})
+			return
+		}
+
 		isStaff := false
 		isPresident := false
 		if access == 1 || access == 2 {
@@ -220,13 +284,43 @@ func CreateNewPost() gin.HandlerFunc {
 			}
 		}
 
+		if request.UpdatedPost.GracePeriod < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gracePeriod must not be negative"})
+			return
+		}
+		if !validResultsVisibility(request.UpdatedPost.ResultsVisibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resultsVisibility"})
+			return
+		}
+		kind, err := lookupPostKind(request.UpdatedPost.Kind)
+		if err != nil {
+			rejectPostKind(c, request.UpdatedPost.Kind, err)
+			return
+		}
+		msg := checkKindTraits(kind, &request.UpdatedPost)
+		if msg == "" {
+			msg = kind.validate(event, request.UpdatedPost)
+		}
+		if msg == "" {
+			msg = validatePostSchedule(request.UpdatedPost)
+		}
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		request.UpdatedPost.Extensions = nil
+		request.UpdatedPost.Revision = 1
+		request.UpdatedPost.QuestionsVersion = 1
+		announced := false
+		request.UpdatedPost.Announced = &announced // Members are notified once it is published
+
 		// Initialize the ID field if it's not already set
 		if request.UpdatedPost.ID.IsZero() {
 			request.UpdatedPost.ID = primitive.NewObjectID()
 		}
 
 		// Insert the post document
-		_, err := postCollection.InsertOne(ctx, request.UpdatedPost)
+		_, err = postCollection.InsertOne(ctx, request.UpdatedPost)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -239,6 +333,11 @@ func CreateNewPost() gin.HandlerFunc {
 			return
 		}
 
+		if err := recordRevision(ctx, request.UpdatedPost, models.PostActionCreate, stdID.(string), nil, 0, time.Now().UTC()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": request})
 	}
 }
@@ -275,37 +374,23 @@ func GetPostFromEvent() gin.HandlerFunc {
 			return
 		}
 
-		isParticipant := false
-		isStaff := false
-		for _, participant := range event.Participants {
-			if participant == userID {
-				isParticipant = true
-				break
-			}
-		}
-		role := ""
-		for _, staff := range event.Staff {
-			if staff.StdID == userID {
-				isStaff = true
-				role = staff.Role
-				break
-			}
-		}
+		access, _ := c.Get("access")
+		member := getMembership(event, userID)
 
 		// Check if the user is a participant or staff in the event
-		if !isParticipant && !isStaff {
+		if !member.IsMember() {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
 
-		// Query the posts collection based on user role
-		var posts []models.Post
-		var cursor *mongo.Cursor
-		if isStaff {
-			cursor, err = postCollection.Find(ctx, bson.M{"_id": bson.M{"$in": event.PostList}, "$or": []bson.M{{"assignTo": role}, {"assignTo": "everyone"}, {"public": true}}})
-		} else {
-			cursor, err = postCollection.Find(ctx, bson.M{"_id": bson.M{"$in": event.PostList}, "public": true})
+		// Query the posts collection based on user role. Posts that are
+		// scheduled or hidden are only listed to the organizers.
+		filter := visiblePostsFilter(event, member)
+		if !canReadAllAnswers(member, access.(int)) {
+			filter["$and"] = livePostsFilter(postClock())["$and"]
 		}
+		var posts []models.Post
+		cursor, err := postCollection.Find(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving posts"})
 			return
@@ -323,18 +408,14 @@ func GetPostFromEvent() gin.HandlerFunc {
 
 		// Convert each post to its specific type based on the Kind
 		for _, post := range posts {
-			specificPost := NewPost(post, false) // Convert to specific type
-			if post.EndDate != nil {
-				postEndDateLocal := post.EndDate.Time()
-				currentTimeLocal := time.Now().Add(time.Hour * 7)
-
-				if postEndDateLocal.Before(currentTimeLocal) {
-					specificPost = NewPost(post, true)
-				}
+			if !canReadAllAnswers(member, access.(int)) {
+				post = hideAnswerKey(post)
 			}
-
-			if specificPost == nil {
-				continue // Or handle unknown kind if needed
+			specificPost, err := NewPost(post, postClosedFor(post, userID.(string))) // Convert to specific type
+			if err != nil {
+				// One post of a kind this server does not know must not hide the others
+				log.Printf("Skipping post %s of kind %q: %v", post.ID.Hex(), post.Kind, err)
+				continue
 			}
 			specificPosts = append(specificPosts, specificPost)
 		}
@@ -411,22 +492,69 @@ func GetPostFromPostId() gin.HandlerFunc {
 		}
 
 		// Convert the post to its specific type based on the Kind
-		specificPost := NewPost(post, false)
-		if post.EndDate != nil {
-			postEndDateLocal := post.EndDate.Time()
-			currentTimeLocal := time.Now().Add(time.Hour * 7)
-
-			if postEndDateLocal.Before(currentTimeLocal) {
-				specificPost = NewPost(post, true)
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+		studentID, _ := userID.(string)
+
+		// Only the organizers see posts that are not published, and the
+		// answer key of a quiz
+		live := postLiveAt(post, postClock())
+		if post.Quiz || !live {
+			event, err := findPostEvent(ctx, objectID)
+			if err != nil || !canReadAllAnswers(getMembership(event, userID), access.(int)) {
+				if !live {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+					return
+				}
+				post = hideAnswerKey(post)
 			}
 		}
-
-		if specificPost == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown post kind"})
+		kind, err := lookupPostKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
 			return
 		}
+		specificPost := kind.response(post, postClosedFor(post, studentID))
 
 		// Respond with the specific post data
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": specificPost})
 	}
 }
+
+// findPostEvent returns the event whose post list holds the post
+func findPostEvent(ctx context.Context, postID primitive.ObjectID) (models.Event, error) {
+	var event models.Event
+	err := eventCollection.FindOne(ctx, bson.M{"postList": postID}).Decode(&event)
+	return event, err
+}
+
+// loadOrganizedPost fetches a post and makes sure the user runs its event
+func loadOrganizedPost(c *gin.Context, ctx context.Context, postID primitive.ObjectID) (models.Post, models.Event, bool) {
+	var post models.Post
+	var event models.Event
+
+	userID, exists := c.Get("studentid")
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		return post, event, false
+	}
+	access, _ := c.Get("access")
+
+	if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return post, event, false
+	}
+
+	event, err := findPostEvent(ctx, postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return post, event, false
+	}
+
+	if access.(int) < 3 && !getMembership(event, userID).IsOrganizer() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can manage this post"})
+		return post, event, false
+	}
+
+	return post, event, true
+}
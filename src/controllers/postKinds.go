@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error codes of posts of a kind the server does not know
+const (
+	ErrCodeUnknownPostKind = "UNKNOWN_POST_KIND"
+	ErrCodeNoAnswers       = "NO_ANSWERS"
+)
+
+var (
+	errUnknownPostKind = errors.New("Unknown post kind")
+	errNoAnswers       = errors.New("This kind of post takes no answers")
+)
+
+// postKind is what sets the posts of one kind apart from the others
+type postKind interface {
+	// validate checks a new or edited post, returning the problem if any
+	validate(event models.Event, post models.Post) string
+	// updateFields are the fields of the kind an edit may change
+	updateFields(post models.Post) bson.M
+	// response is the post as it is sent to members
+	response(post models.Post, timeUp bool) interface{}
+	// traits are the optional features the kind supports
+	traits() kindTraits
+	// edited runs after the post was edited or restored
+	edited(ctx context.Context, post models.Post) error
+}
+
+// kindTraits are the optional features of a kind of post
+type kindTraits struct {
+	anonymous bool // Answers are kept apart from who gave them
+	quiz      bool // Answers are graded against a key
+	drafts    bool // Answers may be saved before they are submitted
+	uploads   bool // Questions may take files
+	assignees bool // Students are assigned by name and report their progress
+}
+
+// answerKind is a kind of post that members answer
+type answerKind interface {
+	postKind
+	// bindAnswer decodes and validates the answer in the request body,
+	// writing the error when it is rejected
+	bindAnswer(c *gin.Context, post models.Post, id primitive.ObjectID, studentID string, meta models.AnswerMeta) (interface{}, bool)
+	// readAnswer returns the answer of a student, nil if there is none. self
+	// tells whether the student is the one reading it.
+	readAnswer(ctx context.Context, post models.Post, studentID string, self bool) (interface{}, error)
+	// summarize aggregates the answers to the post
+	summarize(ctx context.Context, post models.Post, withRespondents bool) (interface{}, error)
+	// export writes the answers to the post as rows
+	export(ctx context.Context, post models.Post, withRespondents bool, rows rowWriter, flush func() error) error
+}
+
+// postKinds holds every kind of post by the name stored in Post.Kind
+var postKinds = map[string]postKind{
+	"post": announcementKind{},
+	"vote": voteKind{},
+	"form": formKind{},
+	"task": taskKind{},
+}
+
+func lookupPostKind(kind string) (postKind, error) {
+	if k, ok := postKinds[kind]; ok {
+		return k, nil
+	}
+	return nil, errUnknownPostKind
+}
+
+func lookupAnswerKind(kind string) (answerKind, error) {
+	k, err := lookupPostKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	if answers, ok := k.(answerKind); ok {
+		return answers, nil
+	}
+	return nil, errNoAnswers
+}
+
+// kindsWith returns the names of the kinds with a trait, for queries
+func kindsWith(has func(kindTraits) bool) []string {
+	var names []string
+	for name, kind := range postKinds {
+		if has(kind.traits()) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkKindTraits rejects the features a post asks for that its kind does
+// not support, and drops the assignees of kinds that are not assigned
+func checkKindTraits(kind postKind, post *models.Post) string {
+	traits := kind.traits()
+	if post.Anonymous && !traits.anonymous {
+		return "This kind of post cannot be anonymous"
+	}
+	if post.Quiz && !traits.quiz {
+		return "This kind of post cannot be a quiz"
+	}
+	if !traits.assignees {
+		post.Assignees = nil
+	}
+	return ""
+}
+
+// rejectPostKind writes the error of a failed kind lookup
+func rejectPostKind(c *gin.Context, kind string, err error) {
+	code := ErrCodeUnknownPostKind
+	if err == errNoAnswers {
+		code = ErrCodeNoAnswers
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": code, "kind": kind})
+}
+
+// announcementKind is a plain post with markdown
+type announcementKind struct{}
+
+func (announcementKind) validate(event models.Event, post models.Post) string {
+	return ""
+}
+
+func (announcementKind) updateFields(post models.Post) bson.M {
+	return bson.M{"markdown": post.Markdown}
+}
+
+func (announcementKind) response(post models.Post, timeUp bool) interface{} {
+	return models.PPost{Post: post, TimeUp: timeUp}
+}
+
+func (announcementKind) traits() kindTraits {
+	return kindTraits{}
+}
+
+func (announcementKind) edited(ctx context.Context, post models.Post) error {
+	return nil
+}
+
+type voteKind struct{}
+
+func (voteKind) validate(event models.Event, post models.Post) string {
+	return validateVoteQuestions(post)
+}
+
+func (voteKind) updateFields(post models.Post) bson.M {
+	return bson.M{"voteQuestions": post.VoteQuestions, "ballot": post.Ballot}
+}
+
+func (voteKind) response(post models.Post, timeUp bool) interface{} {
+	return models.PVote{Post: post, Questions: post.VoteQuestions, TimeUp: timeUp}
+}
+
+func (voteKind) traits() kindTraits {
+	return kindTraits{anonymous: true}
+}
+
+func (voteKind) edited(ctx context.Context, post models.Post) error {
+	return nil
+}
+
+func (voteKind) bindAnswer(c *gin.Context, post models.Post, id primitive.ObjectID, studentID string, meta models.AnswerMeta) (interface{}, bool) {
+	var voteRequest models.AVote
+	if err := c.BindJSON(&voteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if errs := validateVoteAnswer(post, voteRequest); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer does not match the post", "details": errs})
+		return nil, false
+	}
+
+	voteRequest.Answers = voteChoices(voteRequest)
+	voteRequest.Answer = firstChoice(voteRequest.Answers)
+	voteRequest.ID = id
+	voteRequest.PostID = post.ID
+	voteRequest.StudentID = studentID
+	voteRequest.AnswerMeta = meta
+	return voteRequest, true
+}
+
+func (voteKind) readAnswer(ctx context.Context, post models.Post, studentID string, self bool) (interface{}, error) {
+	// Only participation is known for anonymous votes
+	if post.Anonymous {
+		voted, err := hasVotedAnonymously(ctx, post.ID, studentID)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"anonymous": true, "voted": voted}, nil
+	}
+
+	var vote models.AVote
+	if err := transactionCollection.FindOne(ctx, bson.M{"postID": post.ID, "studentID": studentID}).Decode(&vote); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	vote.Answers = voteChoices(vote)
+	return vote, nil
+}
+
+func (voteKind) summarize(ctx context.Context, post models.Post, withRespondents bool) (interface{}, error) {
+	return summarizeVote(ctx, post)
+}
+
+func (voteKind) export(ctx context.Context, post models.Post, withRespondents bool, rows rowWriter, flush func() error) error {
+	return exportVote(ctx, post, rows)
+}
+
+type formKind struct{}
+
+func (formKind) validate(event models.Event, post models.Post) string {
+	return validateFormQuestions(post)
+}
+
+func (formKind) updateFields(post models.Post) bson.M {
+	return bson.M{"formQuestions": post.FormQuestions, "formSections": post.FormSections, "quiz": post.Quiz}
+}
+
+func (formKind) response(post models.Post, timeUp bool) interface{} {
+	return models.PForm{Post: post, Questions: post.FormQuestions, TimeUp: timeUp}
+}
+
+func (formKind) traits() kindTraits {
+	return kindTraits{quiz: true, drafts: true, uploads: true}
+}
+
+// edited regrades the answers, so grades follow the answer key as it is now
+func (formKind) edited(ctx context.Context, post models.Post) error {
+	return regradeQuiz(ctx, post)
+}
+
+func (formKind) bindAnswer(c *gin.Context, post models.Post, id primitive.ObjectID, studentID string, meta models.AnswerMeta) (interface{}, bool) {
+	var formRequest models.AForm
+	if err := c.BindJSON(&formRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if errs := validateFormAnswer(post, formRequest); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer does not match the post", "details": errs})
+		return nil, false
+	}
+
+	formRequest.AnswerList = visibleAnswers(post, formRequest)
+
+	errs, err := checkUploads(c.Request.Context(), post, formRequest, studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer does not match the post", "details": errs})
+		return nil, false
+	}
+
+	formRequest.Score = nil
+	if post.Quiz {
+		formRequest.Score = gradeQuiz(post, formRequest)
+	}
+	formRequest.QuestionsVersion = questionsVersion(post)
+	formRequest.ID = id
+	formRequest.PostID = post.ID
+	formRequest.StudentID = studentID
+	formRequest.AnswerMeta = meta
+	return formRequest, true
+}
+
+func (formKind) readAnswer(ctx context.Context, post models.Post, studentID string, self bool) (interface{}, error) {
+	var form models.AForm
+	if err := transactionCollection.FindOne(ctx, bson.M{"postID": post.ID, "studentID": studentID}).Decode(&form); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if self {
+		return hideScore(post, form, studentID), nil
+	}
+	// Drafts are only shown to the student writing them
+	if form.Status == models.AnswerStatusDraft {
+		return nil, nil
+	}
+	return form, nil
+}
+
+func (formKind) summarize(ctx context.Context, post models.Post, withRespondents bool) (interface{}, error) {
+	return summarizeForm(ctx, post, withRespondents)
+}
+
+func (formKind) export(ctx context.Context, post models.Post, withRespondents bool, rows rowWriter, flush func() error) error {
+	return exportForm(ctx, post, withRespondents, rows, flush)
+}
+
+// taskKind is work assigned to students, who report their progress on it
+// instead of answering
+type taskKind struct{}
+
+func (taskKind) validate(event models.Event, post models.Post) string {
+	return validateTaskPost(event, post)
+}
+
+func (taskKind) updateFields(post models.Post) bson.M {
+	fields := bson.M{"assignees": post.Assignees}
+	// A new due date in the future reminds the assignees again
+	if post.EndDate != nil && *post.EndDate > postClock() {
+		fields["reminded"] = false
+	}
+	return fields
+}
+
+func (taskKind) response(post models.Post, timeUp bool) interface{} {
+	return models.PTask{Post: post, TimeUp: timeUp}
+}
+
+func (taskKind) traits() kindTraits {
+	return kindTraits{assignees: true}
+}
+
+func (taskKind) edited(ctx context.Context, post models.Post) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postClock is the current time on the clock post dates are stored in
+func postClock() primitive.DateTime {
+	return primitive.NewDateTimeFromTime(helper.ToEventClock(time.Now()))
+}
+
+// postLiveAt reports whether a post is shown to students at the given time,
+// that is published and not hidden yet
+func postLiveAt(post models.Post, now primitive.DateTime) bool {
+	if post.PublishAt != nil && *post.PublishAt > now {
+		return false
+	}
+	return post.HideAt == nil || *post.HideAt > now
+}
+
+// livePostsFilter is postLiveAt as a query
+func livePostsFilter(now primitive.DateTime) bson.M {
+	return bson.M{"$and": []bson.M{
+		{"$or": []bson.M{{"publishAt": nil}, {"publishAt": bson.M{"$lte": now}}}},
+		{"$or": []bson.M{{"hideAt": nil}, {"hideAt": bson.M{"$gt": now}}}},
+	}}
+}
+
+// validatePostSchedule checks the publish and hide times of a post
+func validatePostSchedule(post models.Post) string {
+	if post.PublishAt != nil && post.HideAt != nil && *post.HideAt <= *post.PublishAt {
+		return "hideAt must be after publishAt"
+	}
+	if post.HideAt != nil && post.EndDate != nil && *post.HideAt < *post.EndDate {
+		return "hideAt must not be before endDate"
+	}
+	return ""
+}
+
+// PublishScheduledPosts notifies the members of an event of the posts that
+// were published since the last run. Posts from before scheduling existed
+// have no announced flag and are left alone.
+func PublishScheduledPosts() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := postClock()
+	filter := bson.M{
+		"announced": false,
+		"$or":       []bson.M{{"publishAt": nil}, {"publishAt": bson.M{"$lte": now}}},
+	}
+	cursor, err := postCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		// Claiming the post first keeps several instances from announcing it twice
+		result, err := postCollection.UpdateOne(ctx, bson.M{"_id": post.ID, "announced": false}, bson.M{"$set": bson.M{"announced": true}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 || !postLiveAt(post, now) {
+			continue
+		}
+
+		event, err := findPostEvent(ctx, post.ID)
+		if err != nil {
+			log.Printf("Error finding the event of post %s: %v", post.ID.Hex(), err)
+			continue
+		}
+		if event.Status == models.EventStatusDraft {
+			continue
+		}
+
+		postID := post.ID
+		notification := models.Notification{
+			Kind:    models.NotificationPostPublished,
+			EventID: event.ID,
+			PostID:  &postID,
+			Title:   post.Title,
+			Message: "New " + post.Kind + " in " + event.EventName,
+		}
+		if err := notifyStudents(ctx, postAudience(event, post), notification); err != nil {
+			postCollection.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"announced": false}})
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gradable reports whether a quiz question has an answer key
+func gradable(question models.FormQuestion) bool {
+	return len(question.CorrectAnswers) > 0
+}
+
+func questionPoints(question models.FormQuestion) float64 {
+	if question.Points > 0 {
+		return question.Points
+	}
+	return 1
+}
+
+// gradeQuiz scores a submission against the answer key. A question gives its
+// points only when answered exactly right, questions hidden by the form
+// rules do not count.
+func gradeQuiz(post models.Post, form models.AForm) *models.QuizScore {
+	score := &models.QuizScore{Questions: []models.QuestionScore{}}
+	answers := formAnswerMap(form)
+	visible := visibleQuestions(post, answers)
+
+	for i, question := range post.FormQuestions {
+		if !gradable(question) || !visible[i] {
+			continue
+		}
+		result := models.QuestionScore{QuestionIndex: i, Correct: isCorrectAnswer(question, answers[i])}
+		if result.Correct {
+			result.Points = questionPoints(question)
+		}
+		score.Points += result.Points
+		score.MaxPoints += questionPoints(question)
+		score.Questions = append(score.Questions, result)
+	}
+	return score
+}
+
+// isCorrectAnswer compares an answer with the key of its question. Choice
+// questions need exactly the correct options, numbers and text may match
+// any of the accepted answers.
+func isCorrectAnswer(question models.FormQuestion, answers []string) bool {
+	var given []string
+	for _, answer := range answers {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			given = append(given, answer)
+		}
+	}
+	if len(given) == 0 {
+		return false
+	}
+
+	switch questionKind(question) {
+	case questionSingleChoice, questionMultiChoice:
+		correct := validChoices(question.CorrectAnswers, question.CorrectAnswers)
+		chosen := validChoices(given, given)
+		if len(chosen) != len(correct) {
+			return false
+		}
+		for _, option := range chosen {
+			if !containsString(correct, option) {
+				return false
+			}
+		}
+		return true
+	case questionNumeric:
+		value, err := strconv.ParseFloat(given[0], 64)
+		if err != nil {
+			return false
+		}
+		for _, key := range question.CorrectAnswers {
+			if expected, err := strconv.ParseFloat(strings.TrimSpace(key), 64); err == nil && expected == value {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, key := range question.CorrectAnswers {
+			if strings.EqualFold(strings.TrimSpace(key), given[0]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hideAnswerKey removes the answer key from a quiz before it is sent to a
+// student. The questions are copied, the post may be shared.
+func hideAnswerKey(post models.Post) models.Post {
+	if !post.Quiz {
+		return post
+	}
+	questions := make([]models.FormQuestion, len(post.FormQuestions))
+	for i, question := range post.FormQuestions {
+		question.CorrectAnswers = nil
+		questions[i] = question
+	}
+	post.FormQuestions = questions
+	return post
+}
+
+// hideScore keeps the grade of a quiz from the student until their deadline
+// has passed, so it cannot be used to correct the answers
+func hideScore(post models.Post, form models.AForm, studentID string) models.AForm {
+	if post.Quiz && !postClosedFor(post, studentID) {
+		form.Score = nil
+	}
+	return form
+}
+
+// regradeQuiz grades every submission again after the answer key changed
+func regradeQuiz(ctx context.Context, post models.Post) error {
+	if !post.Quiz {
+		_, err := transactionCollection.UpdateMany(ctx, bson.M{"postID": post.ID, "score": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"score": ""}})
+		return err
+	}
+
+	cursor, err := transactionCollection.Find(ctx, submittedAnswers(post.ID))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var forms []models.AForm
+	if err := cursor.All(ctx, &forms); err != nil {
+		return err
+	}
+	// Answers to questions that were edited since earn no points for the new ones
+	forms, _, err = answersToCurrentQuestions(ctx, post, forms)
+	if err != nil {
+		return err
+	}
+
+	for _, form := range forms {
+		update := bson.M{"$set": bson.M{"score": gradeQuiz(post, form)}}
+		if _, err := transactionCollection.UpdateOne(ctx, bson.M{"_id": form.ID}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type leaderboardEntry struct {
+	Rank        int       `json:"rank"`
+	StudentID   string    `json:"studentID"`
+	Name        string    `json:"name"`
+	Points      float64   `json:"points"`
+	MaxPoints   float64   `json:"maxPoints"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+type questionDifficulty struct {
+	QuestionIndex int     `json:"questionIndex"`
+	Question      string  `json:"question"`
+	Points        float64 `json:"points"`
+	Attempts      int     `json:"attempts"` // Students the question was shown to
+	Correct       int     `json:"correct"`
+	CorrectRate   float64 `json:"correctRate"` // Percent, lower is harder
+}
+
+// GetQuizResults gives organizers the leaderboard of a quiz and how many
+// students got each question right
+func GetQuizResults() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		post, _, ok := loadOrganizedPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+		if !post.Quiz {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Post is not a quiz"})
+			return
+		}
+
+		cursor, err := transactionCollection.Find(ctx, submittedAnswers(postID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var answers []models.AForm
+		if err := cursor.All(ctx, &answers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		answers, stale, err := answersToCurrentQuestions(ctx, post, answers)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		studentIDs := make([]string, 0, len(answers))
+		for _, answer := range answers {
+			studentIDs = append(studentIDs, answer.StudentID)
+		}
+		names, err := studentNames(ctx, studentIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		difficulty := make(map[int]*questionDifficulty)
+		for i, question := range post.FormQuestions {
+			if gradable(question) {
+				difficulty[i] = &questionDifficulty{QuestionIndex: i, Question: question.Question, Points: questionPoints(question)}
+			}
+		}
+
+		leaderboard := make([]leaderboardEntry, 0, len(answers))
+		for _, answer := range answers {
+			score := answer.Score
+			if score == nil {
+				score = gradeQuiz(post, answer)
+			}
+			leaderboard = append(leaderboard, leaderboardEntry{
+				StudentID:   answer.StudentID,
+				Name:        names[answer.StudentID],
+				Points:      score.Points,
+				MaxPoints:   score.MaxPoints,
+				SubmittedAt: answer.SubmittedAt,
+			})
+			for _, result := range score.Questions {
+				if stat, ok := difficulty[result.QuestionIndex]; ok {
+					stat.Attempts++
+					if result.Correct {
+						stat.Correct++
+					}
+				}
+			}
+		}
+
+		// Ties go to whoever submitted first
+		sort.SliceStable(leaderboard, func(i, j int) bool {
+			if leaderboard[i].Points != leaderboard[j].Points {
+				return leaderboard[i].Points > leaderboard[j].Points
+			}
+			return leaderboard[i].SubmittedAt.Before(leaderboard[j].SubmittedAt)
+		})
+		for i := range leaderboard {
+			leaderboard[i].Rank = i + 1
+			if i > 0 && leaderboard[i].Points == leaderboard[i-1].Points && leaderboard[i].SubmittedAt.Equal(leaderboard[i-1].SubmittedAt) {
+				leaderboard[i].Rank = leaderboard[i-1].Rank
+			}
+		}
+
+		questions := make([]questionDifficulty, 0, len(difficulty))
+		for i := range post.FormQuestions {
+			if stat, ok := difficulty[i]; ok {
+				stat.CorrectRate = percent(stat.Correct, stat.Attempts)
+				questions = append(questions, *stat)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"postID":       postID,
+			"submissions":  len(answers),
+			"staleAnswers": stale,
+			"leaderboard":  leaderboard,
+			"questions":    questions,
+		}})
+	}
+}
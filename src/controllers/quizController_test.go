@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+func TestIsCorrectAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		question models.FormQuestion
+		answers  []string
+		want     bool
+	}{
+		{"radio right", models.FormQuestion{InputType: models.InputRadio, Options: []string{"A", "B"}, CorrectAnswers: []string{"B"}}, []string{"B"}, true},
+		{"radio wrong", models.FormQuestion{InputType: models.InputRadio, Options: []string{"A", "B"}, CorrectAnswers: []string{"B"}}, []string{"A"}, false},
+		{"checkbox any order", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}, CorrectAnswers: []string{"A", "C"}}, []string{"C", "A"}, true},
+		{"checkbox missing option", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}, CorrectAnswers: []string{"A", "C"}}, []string{"A"}, false},
+		{"checkbox extra option", models.FormQuestion{InputType: models.InputCheckbox, Options: []string{"A", "B", "C"}, CorrectAnswers: []string{"A", "C"}}, []string{"A", "B", "C"}, false},
+		{"number same value", models.FormQuestion{InputType: models.InputNumber, CorrectAnswers: []string{"0.5"}}, []string{" .50 "}, true},
+		{"number any key", models.FormQuestion{InputType: models.InputNumber, CorrectAnswers: []string{"1", "2"}}, []string{"2"}, true},
+		{"number NaN", models.FormQuestion{InputType: models.InputNumber, CorrectAnswers: []string{"NaN"}}, []string{"NaN"}, false},
+		{"text ignores case", models.FormQuestion{InputType: models.InputText, CorrectAnswers: []string{"Bangkok"}}, []string{" bangkok"}, true},
+		{"text wrong", models.FormQuestion{InputType: models.InputText, CorrectAnswers: []string{"Bangkok"}}, []string{"Chiang Mai"}, false},
+		{"blank answer", models.FormQuestion{InputType: models.InputText, CorrectAnswers: []string{""}}, []string{" "}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCorrectAnswer(tt.question, tt.answers); got != tt.want {
+				t.Errorf("isCorrectAnswer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeQuiz(t *testing.T) {
+	post := models.Post{Quiz: true, FormQuestions: []models.FormQuestion{
+		{InputType: models.InputRadio, Options: []string{"Yes", "No"}, CorrectAnswers: []string{"Yes"}, Points: 2},
+		{InputType: models.InputNumber, CorrectAnswers: []string{"42"}},
+		{InputType: models.InputText}, // Not graded
+		{InputType: models.InputText, CorrectAnswers: []string{"why"}, ShowIf: []models.ShowIfCondition{{QuestionIndex: 0, Operator: models.ShowIfEquals, Value: "No"}}},
+	}}
+
+	tests := []struct {
+		name      string
+		answers   []models.AQuestion
+		points    float64
+		maxPoints float64
+		correct   []bool
+	}{
+		{
+			name: "all right",
+			answers: []models.AQuestion{
+				{QuestionIndex: 0, Answers: []string{"Yes"}},
+				{QuestionIndex: 1, Answers: []string{"42"}},
+				{QuestionIndex: 2, Answers: []string{"anything"}},
+			},
+			points: 3, maxPoints: 3,
+			correct: []bool{true, true},
+		},
+		{
+			name: "hidden question counts once shown",
+			answers: []models.AQuestion{
+				{QuestionIndex: 0, Answers: []string{"No"}},
+				{QuestionIndex: 3, Answers: []string{"WHY"}},
+			},
+			points: 1, maxPoints: 4,
+			correct: []bool{false, false, true},
+		},
+		{
+			name:   "nothing answered",
+			points: 0, maxPoints: 3,
+			correct: []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := gradeQuiz(post, models.AForm{AnswerList: tt.answers})
+			if score.Points != tt.points || score.MaxPoints != tt.maxPoints {
+				t.Errorf("score = %v/%v, want %v/%v", score.Points, score.MaxPoints, tt.points, tt.maxPoints)
+			}
+			var correct []bool
+			for _, question := range score.Questions {
+				correct = append(correct, question.Correct)
+			}
+			if !reflect.DeepEqual(correct, tt.correct) {
+				t.Errorf("correct = %v, want %v", correct, tt.correct)
+			}
+		})
+	}
+}
+
+func TestHideAnswerKey(t *testing.T) {
+	post := models.Post{Quiz: true, FormQuestions: []models.FormQuestion{{InputType: models.InputText, CorrectAnswers: []string{"x"}}}}
+
+	hidden := hideAnswerKey(post)
+	if hidden.FormQuestions[0].CorrectAnswers != nil {
+		t.Errorf("answer key was sent: %v", hidden.FormQuestions[0].CorrectAnswers)
+	}
+	if post.FormQuestions[0].CorrectAnswers == nil {
+		t.Error("answer key was removed from the shared post")
+	}
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var postHistoryCollection *mongo.Collection = database.OpenCollection(database.Client, "postHistory")
+
+var errPostChanged = errors.New("Post was changed by another request, try again")
+
+// Fields an edit sets that are bookkeeping rather than content, left out of the diff
+var untrackedPostFields = map[string]bool{"announced": true, "reminded": true}
+
+// questionsVersion is the version of the form questions of a post
+func questionsVersion(post models.Post) int {
+	if post.QuestionsVersion == 0 {
+		return 1
+	}
+	return post.QuestionsVersion
+}
+
+// answeredVersion is the version of the form questions an answer was given to
+func answeredVersion(form models.AForm) int {
+	if form.QuestionsVersion == 0 {
+		return 1
+	}
+	return form.QuestionsVersion
+}
+
+// editablePostFields are the fields an edit of the post sets, those shared
+// by every kind and those of its kind
+func editablePostFields(kind postKind, post models.Post) bson.M {
+	fields := bson.M{
+		"assignTo":          post.AssignTo,
+		"public":            post.Public,
+		"title":             post.Title,
+		"description":       post.Description,
+		"endDate":           post.EndDate,
+		"publishAt":         post.PublishAt,
+		"hideAt":            post.HideAt,
+		"gracePeriod":       post.GracePeriod,
+		"resultsVisibility": post.ResultsVisibility,
+	}
+	// Moving the publication to the future announces the post again then
+	if post.PublishAt != nil && *post.PublishAt > postClock() {
+		fields["announced"] = false
+	}
+	for field, value := range kind.updateFields(post) {
+		fields[field] = value
+	}
+	return fields
+}
+
+// postDocument is the post as it is stored
+func postDocument(post models.Post) (bson.M, error) {
+	data, err := bson.Marshal(post)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// diffPost applies the fields to the post, returning the result and the
+// fields whose content changed
+func diffPost(post models.Post, fields bson.M) (models.Post, []models.FieldChange, error) {
+	var after models.Post
+
+	before, err := postDocument(post)
+	if err != nil {
+		return after, nil, err
+	}
+	merged := bson.M{}
+	for field, value := range before {
+		merged[field] = value
+	}
+	for field, value := range fields {
+		merged[field] = value
+	}
+	data, err := bson.Marshal(merged)
+	if err != nil {
+		return after, nil, err
+	}
+	if err := bson.Unmarshal(data, &after); err != nil {
+		return after, nil, err
+	}
+
+	// Both sides go through the Post struct so empty and missing fields compare equal
+	afterDoc, err := postDocument(after)
+	if err != nil {
+		return after, nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		if !untrackedPostFields[field] {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []models.FieldChange{}
+	for _, field := range names {
+		if !reflect.DeepEqual(before[field], afterDoc[field]) {
+			changes = append(changes, models.FieldChange{Field: field, Before: before[field], After: afterDoc[field]})
+		}
+	}
+	return after, changes, nil
+}
+
+func changesField(changes []models.FieldChange, field string) bool {
+	for _, change := range changes {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRevision adds the post as it is now to its history
+func recordRevision(ctx context.Context, post models.Post, action string, author string, changes []models.FieldChange, restoredFrom int, at time.Time) error {
+	snapshot := post
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+	revision := models.PostRevision{
+		ID:               primitive.NewObjectID(),
+		PostID:           post.ID,
+		Revision:         post.Revision,
+		QuestionsVersion: questionsVersion(post),
+		Action:           action,
+		Author:           author,
+		CreatedAt:        at,
+		RestoredFrom:     restoredFrom,
+		Changes:          changes,
+		Post:             &snapshot,
+	}
+	_, err := postHistoryCollection.InsertOne(ctx, revision)
+	return err
+}
+
+// savePostEdit sets the fields on the stored post and records the change in
+// its history. Edits from other requests in between make it fail with
+// errPostChanged.
+func savePostEdit(ctx context.Context, stored models.Post, fields bson.M, action string, author string, restoredFrom int) (models.Post, error) {
+	after, changes, err := diffPost(stored, fields)
+	if err != nil {
+		return stored, err
+	}
+
+	filter := bson.M{"_id": stored.ID, "revision": stored.Revision}
+	if stored.Revision == 0 {
+		filter["revision"] = bson.M{"$exists": false}
+	}
+
+	if len(changes) > 0 {
+		// Posts from before the history was kept start it with their content as it is
+		if stored.Revision == 0 {
+			stored.Revision = 1
+			// A failed edit before may have recorded it already
+			err := recordRevision(ctx, stored, models.PostActionCreate, stored.Author, nil, 0, stored.PostDate.Time().UTC())
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return stored, err
+			}
+		}
+		after.Revision = stored.Revision + 1
+		fields["revision"] = after.Revision
+		if changesField(changes, "formQuestions") {
+			after.QuestionsVersion = questionsVersion(stored) + 1
+			fields["questionsVersion"] = after.QuestionsVersion
+		}
+	}
+
+	result, err := postCollection.UpdateOne(ctx, filter, bson.M{"$set": fields})
+	if err != nil {
+		return stored, err
+	}
+	if result.MatchedCount == 0 {
+		return stored, errPostChanged
+	}
+
+	if len(changes) > 0 {
+		if err := recordRevision(ctx, after, action, author, changes, restoredFrom, time.Now().UTC()); err != nil {
+			return after, err
+		}
+	}
+	return after, nil
+}
+
+// GetPostHistory lists the revisions of a post, newest first, without their content
+func GetPostHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		post, _, ok := loadOrganizedPost(c, ctx, postID)
+		if !ok {
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "revision", Value: -1}}).SetProjection(bson.M{"post": 0})
+		cursor, err := postHistoryCollection.Find(ctx, bson.M{"postID": postID}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		revisions := []models.PostRevision{}
+		if err := cursor.All(ctx, &revisions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": revisions, "revision": post.Revision})
+	}
+}
+
+// loadRevision reads the revision in the URL of an organized post
+func loadRevision(c *gin.Context, ctx context.Context) (models.Post, models.Event, models.PostRevision, bool) {
+	var revision models.PostRevision
+
+	postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+		return models.Post{}, models.Event{}, revision, false
+	}
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return models.Post{}, models.Event{}, revision, false
+	}
+
+	post, event, ok := loadOrganizedPost(c, ctx, postID)
+	if !ok {
+		return post, event, revision, false
+	}
+
+	if err := postHistoryCollection.FindOne(ctx, bson.M{"postID": postID, "revision": number}).Decode(&revision); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return post, event, revision, false
+	}
+	return post, event, revision, true
+}
+
+// GetPostRevision returns a revision of a post with its content
+func GetPostRevision() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		_, _, revision, ok := loadRevision(c, ctx)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": revision})
+	}
+}
+
+// RestorePostRevision brings the content of a post back to an older revision,
+// recorded as a new revision
+func RestorePostRevision() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+
+		post, event, revision, ok := loadRevision(c, ctx)
+		if !ok {
+			return
+		}
+		if revision.Post == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision has no content"})
+			return
+		}
+		old := *revision.Post
+
+		kind, err := lookupPostKind(old.Kind)
+		if err != nil {
+			rejectPostKind(c, old.Kind, err)
+			return
+		}
+		// The rules may have changed since, the old content has to pass them now
+		msg := kind.validate(event, old)
+		if msg == "" {
+			msg = validatePostSchedule(old)
+		}
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		restored, err := savePostEdit(ctx, post, editablePostFields(kind, old), models.PostActionRestore, userID.(string), revision.Revision)
+		if err == errPostChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := kind.edited(ctx, restored); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": restored})
+	}
+}
+
+// answersToCurrentQuestions drops the answers to questions that changed since
+// they were given, so they are not counted under the new question. It also
+// returns how many forms lost answers that way.
+func answersToCurrentQuestions(ctx context.Context, post models.Post, forms []models.AForm) ([]models.AForm, int, error) {
+	current := questionsVersion(post)
+	older := make(map[int][]models.FormQuestion)
+	for _, form := range forms {
+		if version := answeredVersion(form); version != current {
+			older[version] = nil
+		}
+	}
+
+	for version := range older {
+		var revision models.PostRevision
+		opts := options.FindOne().SetSort(bson.D{{Key: "revision", Value: 1}})
+		err := postHistoryCollection.FindOne(ctx, bson.M{"postID": post.ID, "questionsVersion": version}, opts).Decode(&revision)
+		if err == mongo.ErrNoDocuments {
+			delete(older, version) // Not in the history, taken as unchanged
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		older[version] = revision.Post.FormQuestions
+	}
+
+	stale := 0
+	for i, form := range forms {
+		questions, ok := older[answeredVersion(form)]
+		if !ok {
+			continue
+		}
+		kept := make([]models.AQuestion, 0, len(form.AnswerList))
+		for _, answer := range form.AnswerList {
+			index := answer.QuestionIndex
+			if index >= 0 && index < len(questions) && index < len(post.FormQuestions) && sameQuestion(questions[index], post.FormQuestions[index]) {
+				kept = append(kept, answer)
+			}
+		}
+		if len(kept) < len(form.AnswerList) {
+			stale++
+		}
+		forms[i].AnswerList = kept
+	}
+	return forms, stale, nil
+}
+
+// sameQuestion reports whether answers to one question still answer the other
+func sameQuestion(a, b models.FormQuestion) bool {
+	return a.Question == b.Question && questionKind(a) == questionKind(b) && reflect.DeepEqual(a.Options, b.Options)
+}
+
+// deletePostHistory removes the history of a post
+func deletePostHistory(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := postHistoryCollection.DeleteMany(ctx, bson.M{"postID": postID})
+	return err
+}
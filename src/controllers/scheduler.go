@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"log"
+	"time"
+)
+
+// StartScheduler runs the periodic background jobs until the process exits
+func StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runScheduledJobs()
+		<-ticker.C
+	}
+}
+
+func runScheduledJobs() {
+	if err := AdvanceEventStatuses(); err != nil {
+		log.Println("Error advancing event statuses:", err)
+	}
+	if err := PublishScheduledPosts(); err != nil {
+		log.Println("Error publishing scheduled posts:", err)
+	}
+	if err := PurgeExpiredDrafts(); err != nil {
+		log.Println("Error purging expired drafts:", err)
+	}
+	if err := RemindOverdueTasks(); err != nil {
+		log.Println("Error reminding overdue tasks:", err)
+	}
+	if err := FlushClosedBallots(); err != nil {
+		log.Println("Error writing ballots of closed votes:", err)
+	}
+}
@@ -0,0 +1,466 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taskProgressCollection *mongo.Collection = database.OpenCollection(database.Client, "taskProgress")
+
+// taskAssignees lists the students a task is assigned to, the staff of its
+// roles and the members named in it
+func taskAssignees(event models.Event, post models.Post) []string {
+	seen := make(map[string]bool)
+	var assignees []string
+	add := func(studentID string) {
+		if !seen[studentID] {
+			seen[studentID] = true
+			assignees = append(assignees, studentID)
+		}
+	}
+
+	for _, staff := range event.Staff {
+		if containsString(post.AssignTo, staff.Role) || containsString(post.AssignTo, "everyone") {
+			add(staff.StdID)
+		}
+	}
+	for _, studentID := range post.Assignees {
+		if getMembership(event, studentID).IsMember() {
+			add(studentID)
+		}
+	}
+	return assignees
+}
+
+// taskRole is the role a task assignee is counted under on the dashboard
+func taskRole(m membership) string {
+	if m.IsStaff {
+		return m.StaffRole
+	}
+	return "participant"
+}
+
+// validateTaskPost checks that a task is assigned to someone and that the
+// students named in it are members of the event
+func validateTaskPost(event models.Event, post models.Post) string {
+	for _, studentID := range post.Assignees {
+		if !getMembership(event, studentID).IsMember() {
+			return fmt.Sprintf("assignee %s is not a member of the event", studentID)
+		}
+	}
+	if len(taskAssignees(event, post)) == 0 && len(post.AssignTo) == 0 {
+		return "a task needs assignTo roles or assignees"
+	}
+	return ""
+}
+
+func validTaskStatus(status string) bool {
+	switch status {
+	case models.TaskTodo, models.TaskInProgress, models.TaskDone:
+		return true
+	}
+	return false
+}
+
+// loadTaskProgress returns the reported progress of every assignee of the
+// given tasks, by post and student
+func loadTaskProgress(ctx context.Context, postIDs []primitive.ObjectID) (map[primitive.ObjectID]map[string]models.TaskProgress, error) {
+	cursor, err := taskProgressCollection.Find(ctx, bson.M{"postID": bson.M{"$in": postIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.TaskProgress
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	progress := make(map[primitive.ObjectID]map[string]models.TaskProgress)
+	for _, record := range records {
+		if progress[record.PostID] == nil {
+			progress[record.PostID] = make(map[string]models.TaskProgress)
+		}
+		progress[record.PostID][record.StudentID] = record
+	}
+	return progress, nil
+}
+
+// assigneeProgress is the status of one assignee as listed to organizers
+type assigneeProgress struct {
+	StudentID   string     `json:"studentID"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Overdue     bool       `json:"overdue"`
+}
+
+func taskProgressOf(event models.Event, post models.Post, studentID string, records map[string]models.TaskProgress) assigneeProgress {
+	progress := assigneeProgress{
+		StudentID: studentID,
+		Role:      taskRole(getMembership(event, studentID)),
+		Status:    models.TaskTodo,
+	}
+	if record, ok := records[studentID]; ok {
+		progress.Status = record.Status
+		progress.UpdatedAt = &record.UpdatedAt
+		progress.CompletedAt = record.CompletedAt
+	}
+	progress.Overdue = progress.Status != models.TaskDone && postClosedFor(post, studentID)
+	return progress
+}
+
+// UpdateTaskStatus records the progress of the logged in student on a task
+// they are assigned to
+func UpdateTaskStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+		studentID := userID.(string)
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		var request struct {
+			Status string `json:"status"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validTaskStatus(request.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be todo, in_progress or done"})
+			return
+		}
+
+		var post models.Post
+		if err := postCollection.FindOne(ctx, bson.M{"_id": postID, "kind": bson.M{"$in": assignedKinds()}}).Decode(&post); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		event, err := findPostEvent(ctx, postID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		member := getMembership(event, studentID)
+		if !member.IsOrganizer() && !postLiveAt(post, postClock()) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		if !containsString(taskAssignees(event, post), studentID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned to this task"})
+			return
+		}
+
+		now := time.Now().UTC()
+		update := bson.M{
+			"$set":         bson.M{"status": request.Status, "updatedAt": now},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		}
+		if request.Status == models.TaskDone {
+			update["$set"].(bson.M)["completedAt"] = now
+		} else {
+			update["$unset"] = bson.M{"completedAt": ""}
+		}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var progress models.TaskProgress
+		err = taskProgressCollection.FindOneAndUpdate(ctx, bson.M{"postID": postID, "studentID": studentID}, update, opts).Decode(&progress)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": progress})
+	}
+}
+
+// GetTaskProgress lists the progress of every assignee of a task to the
+// organizers, and their own progress to assignees
+func GetTaskProgress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+
+		var post models.Post
+		if err := postCollection.FindOne(ctx, bson.M{"_id": postID, "kind": bson.M{"$in": assignedKinds()}}).Decode(&post); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		event, err := findPostEvent(ctx, postID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		assignees := taskAssignees(event, post)
+		if !canReadAllAnswers(getMembership(event, userID), access.(int)) {
+			if !containsString(assignees, userID.(string)) || !postLiveAt(post, postClock()) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned to this task"})
+				return
+			}
+			assignees = []string{userID.(string)}
+		}
+
+		progress, err := loadTaskProgress(ctx, []primitive.ObjectID{postID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		names, err := studentNames(ctx, assignees)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		list := make([]assigneeProgress, 0, len(assignees))
+		for _, studentID := range assignees {
+			item := taskProgressOf(event, post, studentID, progress[postID])
+			item.Name = names[studentID]
+			list = append(list, item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"postID":    postID,
+			"dueDate":   post.EndDate,
+			"assignees": list,
+		}})
+	}
+}
+
+// taskCounts is how far a group of assignees is with their tasks
+type taskCounts struct {
+	Assigned   int     `json:"assigned"`
+	Todo       int     `json:"todo"`
+	InProgress int     `json:"inProgress"`
+	Done       int     `json:"done"`
+	Overdue    int     `json:"overdue"`
+	Completion float64 `json:"completion"` // Percent done
+}
+
+func (t *taskCounts) add(progress assigneeProgress) {
+	t.Assigned++
+	switch progress.Status {
+	case models.TaskInProgress:
+		t.InProgress++
+	case models.TaskDone:
+		t.Done++
+	default:
+		t.Todo++
+	}
+	if progress.Overdue {
+		t.Overdue++
+	}
+	t.Completion = percent(t.Done, t.Assigned)
+}
+
+type roleCounts struct {
+	Role string `json:"role"`
+	taskCounts
+}
+
+type taskSummary struct {
+	PostID  primitive.ObjectID  `json:"postID"`
+	Title   string              `json:"title"`
+	DueDate *primitive.DateTime `json:"dueDate"`
+	taskCounts
+	Roles []roleCounts `json:"roles"`
+}
+
+// sortedRoles turns counts by role into a list ordered by role
+func sortedRoles(byRole map[string]*taskCounts) []roleCounts {
+	roles := make([]roleCounts, 0, len(byRole))
+	for role, counts := range byRole {
+		roles = append(roles, roleCounts{Role: role, taskCounts: *counts})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Role < roles[j].Role })
+	return roles
+}
+
+// GetTaskDashboard shows the organizers of an event how far every task is,
+// overall and per role
+func GetTaskDashboard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		eventID, err := primitive.ObjectIDFromHex(c.Param("eventID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid eventID format"})
+			return
+		}
+
+		var event models.Event
+		if err := eventCollection.FindOne(ctx, bson.M{"_id": eventID}).Decode(&event); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if !canReadAllAnswers(getMembership(event, userID), access.(int)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can see the task dashboard"})
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "endDate", Value: 1}, {Key: "_id", Value: 1}})
+		cursor, err := postCollection.Find(ctx, bson.M{"_id": bson.M{"$in": event.PostList}, "kind": bson.M{"$in": assignedKinds()}}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving posts"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var posts []models.Post
+		if err := cursor.All(ctx, &posts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding posts"})
+			return
+		}
+
+		postIDs := make([]primitive.ObjectID, 0, len(posts))
+		for _, post := range posts {
+			postIDs = append(postIDs, post.ID)
+		}
+		progress, err := loadTaskProgress(ctx, postIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tasks := make([]taskSummary, 0, len(posts))
+		overall := make(map[string]*taskCounts)
+		for _, post := range posts {
+			summary := taskSummary{PostID: post.ID, Title: post.Title, DueDate: post.EndDate}
+			byRole := make(map[string]*taskCounts)
+			for _, studentID := range taskAssignees(event, post) {
+				item := taskProgressOf(event, post, studentID, progress[post.ID])
+				summary.add(item)
+				if byRole[item.Role] == nil {
+					byRole[item.Role] = &taskCounts{}
+				}
+				byRole[item.Role].add(item)
+				if overall[item.Role] == nil {
+					overall[item.Role] = &taskCounts{}
+				}
+				overall[item.Role].add(item)
+			}
+			summary.Roles = sortedRoles(byRole)
+			tasks = append(tasks, summary)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"eventID": eventID,
+			"tasks":   tasks,
+			"roles":   sortedRoles(overall),
+		}})
+	}
+}
+
+// RemindOverdueTasks notifies the assignees of tasks that are past their
+// due date and not done, once per task
+func RemindOverdueTasks() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := postClock()
+	filter := bson.M{"kind": bson.M{"$in": assignedKinds()}, "endDate": bson.M{"$ne": nil, "$lte": now}, "reminded": bson.M{"$ne": true}}
+	cursor, err := postCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		// Claiming the task first keeps several instances from reminding twice
+		result, err := postCollection.UpdateOne(ctx, bson.M{"_id": post.ID, "reminded": bson.M{"$ne": true}}, bson.M{"$set": bson.M{"reminded": true}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 || !postLiveAt(post, now) {
+			continue
+		}
+
+		event, err := findPostEvent(ctx, post.ID)
+		if err != nil {
+			log.Printf("Error finding the event of task %s: %v", post.ID.Hex(), err)
+			continue
+		}
+		progress, err := loadTaskProgress(ctx, []primitive.ObjectID{post.ID})
+		if err != nil {
+			return err
+		}
+
+		var pending []string
+		for _, studentID := range taskAssignees(event, post) {
+			if progress[post.ID][studentID].Status != models.TaskDone {
+				pending = append(pending, studentID)
+			}
+		}
+
+		postID := post.ID
+		notification := models.Notification{
+			Kind:    models.NotificationTaskOverdue,
+			EventID: event.ID,
+			PostID:  &postID,
+			Title:   post.Title,
+			Message: "Task in " + event.EventName + " is overdue",
+		}
+		if err := notifyStudents(ctx, pending, notification); err != nil {
+			postCollection.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"reminded": false}})
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTaskProgress removes the progress reported on a task
+func deleteTaskProgress(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := taskProgressCollection.DeleteMany(ctx, bson.M{"postID": postID})
+	return err
+}
+
+// assignedKinds are the kinds of posts assigned to students, whose progress
+// is tracked here
+func assignedKinds() []string {
+	return kindsWith(func(traits kindTraits) bool { return traits.assignees })
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxHoursPerDay is what each day of an event counts at most when the
+// organizer has not set its hours
+const maxHoursPerDay = 8
+
+var errStudentNotFound = errors.New("student not found")
+
+// GetMyTranscript returns the transcript of the logged in student
+func GetMyTranscript() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+		writeTranscript(c, userID.(string))
+	}
+}
+
+// GetStudentTranscript lets the department look up any student
+func GetStudentTranscript() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeTranscript(c, c.Param("studentID"))
+	}
+}
+
+// writeTranscript renders the transcript in the format given by ?format=json|csv|pdf
+func writeTranscript(c *gin.Context, studentID string) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	transcript, err := buildTranscript(ctx, studentID)
+	if err == errStudentNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "transcript-" + studentID
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": transcript})
+	case "csv":
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+".csv\"")
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		if err := writeTranscriptCSV(c, transcript); err != nil {
+			c.Error(err)
+		}
+	case "pdf":
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+".pdf\"")
+		c.Header("Content-Type", "application/pdf")
+		if err := writeTranscriptPDF(c, transcript); err != nil {
+			c.Error(err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or pdf"})
+	}
+}
+
+func buildTranscript(ctx context.Context, studentID string) (models.Transcript, error) {
+	transcript := models.Transcript{StudentID: studentID, GeneratedAt: time.Now().UTC(), Entries: []models.TranscriptEntry{}}
+
+	var user struct {
+		FirstName string `bson:"firstName"`
+		LastName  string `bson:"lastName"`
+		Year      int    `bson:"year"`
+	}
+	projection := bson.M{"firstName": 1, "lastName": 1, "year": 1}
+	if err := userCollection.FindOne(ctx, bson.M{"studentID": studentID}, options.FindOne().SetProjection(projection)).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return transcript, errStudentNotFound
+		}
+		return transcript, err
+	}
+	transcript.Name = user.FirstName + " " + user.LastName
+	transcript.Year = user.Year
+
+	filter := bson.M{
+		"$or":    []bson.M{{"participants": studentID}, {"staff.stdID": studentID}},
+		"status": bson.M{"$ne": models.EventStatusDraft},
+	}
+	cursor, err := eventCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "startDate", Value: 1}}))
+	if err != nil {
+		return transcript, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return transcript, err
+	}
+
+	eventIDs := make([]primitive.ObjectID, 0, len(events))
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.ID)
+	}
+
+	// Events where nobody was checked in never took attendance
+	recorded := make(map[primitive.ObjectID]bool)
+	taken, err := checkInCollection.Distinct(ctx, "eventID", bson.M{"eventID": bson.M{"$in": eventIDs}})
+	if err != nil {
+		return transcript, err
+	}
+	for _, id := range taken {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			recorded[oid] = true
+		}
+	}
+
+	checkInCursor, err := checkInCollection.Find(ctx, bson.M{"studentID": studentID, "eventID": bson.M{"$in": eventIDs}})
+	if err != nil {
+		return transcript, err
+	}
+	defer checkInCursor.Close(ctx)
+
+	var checkIns []models.CheckIn
+	if err := checkInCursor.All(ctx, &checkIns); err != nil {
+		return transcript, err
+	}
+	checkedIn := make(map[primitive.ObjectID]models.CheckIn, len(checkIns))
+	for _, checkIn := range checkIns {
+		checkedIn[checkIn.EventID] = checkIn
+	}
+
+	for _, event := range events {
+		member := getMembership(event, studentID)
+		entry := models.TranscriptEntry{
+			EventID:    event.ID,
+			EventName:  event.EventName,
+			Kind:       event.Kind,
+			Role:       "participant",
+			StartDate:  event.StartDate,
+			EndDate:    event.EndDate,
+			Status:     currentEventStatus(event),
+			Hours:      eventHours(event),
+			Attendance: models.AttendanceNotRecorded,
+		}
+		if member.IsStaff {
+			entry.Role = "staff"
+			entry.StaffRole = member.StaffRole
+		}
+
+		// Hours are only earned once the event is over
+		completed := entry.Status == models.EventStatusFinished || entry.Status == models.EventStatusArchived
+		if checkIn, ok := checkedIn[event.ID]; ok {
+			entry.Attendance = models.AttendanceAttended
+			entry.CheckedInAt = &checkIn.CheckedInAt
+			transcript.AttendedCount++
+			if completed {
+				transcript.AttendedHours += entry.Hours
+			}
+		} else if recorded[event.ID] {
+			entry.Attendance = models.AttendanceAbsent
+		}
+
+		if completed {
+			transcript.TotalHours += entry.Hours
+		}
+		transcript.Entries = append(transcript.Entries, entry)
+	}
+	transcript.TotalEvents = len(transcript.Entries)
+	transcript.TotalHours = roundHours(transcript.TotalHours)
+	transcript.AttendedHours = roundHours(transcript.AttendedHours)
+
+	return transcript, nil
+}
+
+// eventHours is the hours the organizer credited for the event, otherwise
+// its length with each day counting at most maxHoursPerDay, rounded to a
+// tenth of an hour
+func eventHours(event models.Event) float64 {
+	if event.Hours != nil {
+		return roundHours(*event.Hours)
+	}
+	if event.StartDate.IsZero() || event.EndDate.IsZero() || event.EndDate.Before(event.StartDate) {
+		return 0
+	}
+	span := event.EndDate.Sub(event.StartDate).Hours()
+	days := math.Ceil(span / 24)
+	return roundHours(math.Min(span, days*maxHoursPerDay))
+}
+
+func roundHours(hours float64) float64 {
+	return math.Round(hours*10) / 10
+}
+
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', 1, 64)
+}
+
+func writeTranscriptCSV(c *gin.Context, transcript models.Transcript) error {
+	// Event names are typed by organizers, so formulas are escaped
+	rows := csvRowWriter{w: csv.NewWriter(c.Writer)}
+	rows.WriteRow([]string{"Event", "Kind", "Role", "Staff Role", "Start", "End", "Hours", "Attendance", "Checked In At"})
+	for _, entry := range transcript.Entries {
+		checkedInAt := ""
+		if entry.CheckedInAt != nil {
+			checkedInAt = entry.CheckedInAt.Format(time.RFC3339)
+		}
+		rows.WriteRow([]string{
+			entry.EventName,
+			entry.Kind,
+			entry.Role,
+			entry.StaffRole,
+			entry.StartDate.Format(time.RFC3339),
+			entry.EndDate.Format(time.RFC3339),
+			formatHours(entry.Hours),
+			entry.Attendance,
+			checkedInAt,
+		})
+	}
+	return rows.Close()
+}
+
+func writeTranscriptPDF(c *gin.Context, transcript models.Transcript) error {
+	pdf := helper.NewPDF()
+	pdf.SetTitle("Co-curricular Activity Transcript", true)
+	pdf.AddPage()
+
+	pdf.SetFont(helper.PDFFont, "B", 16)
+	pdf.CellFormat(0, 10, "Co-curricular Activity Transcript", "", 1, "C", false, 0, "")
+
+	pdf.SetFont(helper.PDFFont, "", 11)
+	pdf.CellFormat(0, 7, fmt.Sprintf("Student: %s (%s)", transcript.Name, transcript.StudentID), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 7, fmt.Sprintf("Year: %d", transcript.Year), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 7, "Generated: "+transcript.GeneratedAt.Format("2006-01-02 15:04 MST"), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	widths := []float64{62, 22, 28, 28, 16, 34}
+	headers := []string{"Event", "Role", "Start", "End", "Hours", "Attendance"}
+	pdf.SetFont(helper.PDFFont, "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 8, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(helper.PDFFont, "", 9)
+	for _, entry := range transcript.Entries {
+		role := entry.Role
+		if entry.StaffRole != "" {
+			role += " (" + entry.StaffRole + ")"
+		}
+		cells := []string{
+			entry.EventName,
+			role,
+			entry.StartDate.Format("2006-01-02"),
+			entry.EndDate.Format("2006-01-02"),
+			formatHours(entry.Hours),
+			entry.Attendance,
+		}
+		for i, cell := range cells {
+			align := "L"
+			if i >= 2 {
+				align = "C"
+			}
+			pdf.CellFormat(widths[i], 7, truncateCell(pdf, cell, widths[i]), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont(helper.PDFFont, "B", 10)
+	pdf.CellFormat(0, 7, fmt.Sprintf("Events joined: %d    Attended: %d", transcript.TotalEvents, transcript.AttendedCount), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 7, fmt.Sprintf("Total hours: %s    Attended hours: %s", formatHours(transcript.TotalHours), formatHours(transcript.AttendedHours)), "", 1, "L", false, 0, "")
+
+	return pdf.Output(c.Writer)
+}
+
+// truncateCell shortens text so it fits inside a table cell
+func truncateCell(pdf *fpdf.Fpdf, text string, width float64) string {
+	const padding = 2
+	if pdf.GetStringWidth(text) <= width-padding {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	database "github.com/encall/cpeevent-backend/src/database"
+	helper "github.com/encall/cpeevent-backend/src/helpers"
+	models "github.com/encall/cpeevent-backend/src/models"
+	"github.com/encall/cpeevent-backend/src/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var uploadCollection *mongo.Collection = database.OpenCollection(database.Client, "uploads")
+
+// maxUploadSize applies to file questions without their own limit
+var maxUploadSize = uploadSizeLimit()
+
+// defaultUploadTypes are accepted by file questions without their own list
+var defaultUploadTypes = []string{"image/jpeg", "image/png", "application/pdf"}
+
+func uploadSizeLimit() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_SIZE_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = 10
+	}
+	return int64(megabytes) << 20
+}
+
+func fileSizeLimit(question models.FormQuestion) int64 {
+	if question.MaxFileSize > 0 {
+		return question.MaxFileSize
+	}
+	return maxUploadSize
+}
+
+// maxFiles is how many files a file question takes, one unless max says otherwise
+func maxFiles(question models.FormQuestion) int {
+	if question.Max != nil && *question.Max >= 1 {
+		return int(*question.Max)
+	}
+	return 1
+}
+
+// allowedContentType matches a sniffed type against the list of the
+// question, where "image/*" stands for every image type
+func allowedContentType(question models.FormQuestion, contentType string) bool {
+	allowed := question.AllowedTypes
+	if len(allowed) == 0 {
+		allowed = defaultUploadTypes
+	}
+	return matchesContentType(allowed, contentType)
+}
+
+// matchesContentType reports whether the type is one of the patterns
+func matchesContentType(allowed []string, contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, pattern := range allowed {
+		if pattern == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadAnswerFile stores a file for a file question. The returned ID is
+// then given as the answer to the question.
+func UploadAnswerFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, exists := c.Get("studentid")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+		studentID := userID.(string)
+
+		postID, err := primitive.ObjectIDFromHex(c.Param("postID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postID format"})
+			return
+		}
+		index, err := strconv.Atoi(c.Param("questionIndex"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid questionIndex"})
+			return
+		}
+
+		var post models.Post
+		if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return
+		}
+		kind, err := lookupPostKind(post.Kind)
+		if err != nil {
+			rejectPostKind(c, post.Kind, err)
+			return
+		}
+		if !kind.traits().uploads || index < 0 || index >= len(post.FormQuestions) || post.FormQuestions[index].InputType != models.InputFile {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Question does not take files"})
+			return
+		}
+		question := post.FormQuestions[index]
+
+		if rejectLateAnswer(c, post, studentID) {
+			return
+		}
+
+		event, err := findPostEvent(ctx, postID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if !getMembership(event, studentID).IsMember() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User is not part of the event"})
+			return
+		}
+
+		// Leave room for the multipart framing, the file itself is checked below
+		limit := fileSizeLimit(question)
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+		header, err := c.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxFileSize": limit})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if header.Size > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxFileSize": limit})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+
+		// The type is taken from the content, the name and header can be anything
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading file"})
+			return
+		}
+		head = head[:n]
+		contentType := http.DetectContentType(head)
+		if !allowedContentType(question, contentType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type is not allowed", "contentType": contentType})
+			return
+		}
+
+		upload := models.Upload{
+			ID:            primitive.NewObjectID(),
+			PostID:        postID,
+			QuestionIndex: index,
+			StudentID:     studentID,
+			FileName:      filepath.Base(header.Filename),
+			ContentType:   contentType,
+			Size:          header.Size,
+			UploadedAt:    time.Now().UTC(),
+		}
+		upload.Key = "answers/" + postID.Hex() + "/" + upload.ID.Hex()
+
+		content := io.MultiReader(bytes.NewReader(head), file)
+		if err := storage.Backend.Save(ctx, upload.Key, content); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing file"})
+			return
+		}
+		if _, err := uploadCollection.InsertOne(ctx, upload); err != nil {
+			storage.Backend.Delete(ctx, upload.Key)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": upload})
+	}
+}
+
+// GetUploadLink returns a short-lived download link to a file, for the
+// student who sent it and the organizers of the event
+func GetUploadLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("studentid")
+		access, _ := c.Get("access")
+
+		uploadID, err := primitive.ObjectIDFromHex(c.Param("uploadID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uploadID format"})
+			return
+		}
+
+		var upload models.Upload
+		if err := uploadCollection.FindOne(ctx, bson.M{"_id": uploadID}).Decode(&upload); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		if userID != upload.StudentID {
+			event, err := findPostEvent(ctx, upload.PostID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+				return
+			}
+			if !canReadAllAnswers(getMembership(event, userID), access.(int)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You can only download your own files"})
+				return
+			}
+		}
+
+		token, expiresAt, err := helper.GenerateFileToken(upload.ID.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating download link"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"file":      upload,
+			"url":       "/api/v1/files/" + token,
+			"expiresAt": expiresAt,
+		}})
+	}
+}
+
+// DownloadFile serves a file to whoever holds a valid download link
+func DownloadFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		claims, err := helper.ValidateFileToken(c.Param("token"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired download link"})
+			return
+		}
+
+		uploadID, err := primitive.ObjectIDFromHex(claims.FileID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+
+		var upload models.Upload
+		if err := uploadCollection.FindOne(ctx, bson.M{"_id": uploadID}).Decode(&upload); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		reader, err := storage.Backend.Open(ctx, upload.Key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading file"})
+			return
+		}
+		defer reader.Close()
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": upload.FileName})
+		c.DataFromReader(http.StatusOK, upload.Size, upload.ContentType, reader, map[string]string{
+			"Content-Disposition":    disposition,
+			"Cache-Control":          "private, no-store",
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+// checkUploads makes sure the files of a form answer were sent by the
+// student for the same question
+func checkUploads(ctx context.Context, post models.Post, form models.AForm, studentID string) ([]models.AnswerError, error) {
+	wanted := make(map[primitive.ObjectID]int)
+	for _, answer := range form.AnswerList {
+		if answer.QuestionIndex < 0 || answer.QuestionIndex >= len(post.FormQuestions) || questionKind(post.FormQuestions[answer.QuestionIndex]) != questionFile {
+			continue
+		}
+		for _, id := range answer.Answers {
+			if uploadID, err := primitive.ObjectIDFromHex(id); err == nil {
+				wanted[uploadID] = answer.QuestionIndex
+			}
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(wanted))
+	for id := range wanted {
+		ids = append(ids, id)
+	}
+	cursor, err := uploadCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []models.Upload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return nil, err
+	}
+	found := make(map[primitive.ObjectID]models.Upload, len(uploads))
+	for _, upload := range uploads {
+		found[upload.ID] = upload
+	}
+
+	var errs []models.AnswerError
+	for id, index := range wanted {
+		upload, ok := found[id]
+		if !ok || upload.PostID != post.ID || upload.StudentID != studentID || upload.QuestionIndex != index {
+			errs = append(errs, models.AnswerError{QuestionIndex: index, Code: models.AnswerErrInvalidFile, Message: "file " + id.Hex() + " was not uploaded for this question"})
+		}
+	}
+	return errs, nil
+}
+
+// deleteUploads removes the files sent for a post from the storage
+func deleteUploads(ctx context.Context, postID primitive.ObjectID) error {
+	return deleteUploadsMatching(ctx, bson.M{"postID": postID})
+}
+
+func deleteUploadsMatching(ctx context.Context, filter bson.M) error {
+	cursor, err := uploadCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []models.Upload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		if err := storage.Backend.Delete(ctx, upload.Key); err != nil {
+			log.Printf("Error deleting file %s: %v", upload.Key, err)
+			return err
+		}
+	}
+
+	_, err = uploadCollection.DeleteMany(ctx, filter)
+	return err
+}
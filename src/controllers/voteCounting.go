@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ballotQuestions returns the questions of a vote post. Posts from before
+// multi-question ballots only have voteQuestions.
+func ballotQuestions(post models.Post) []models.VoteQuestion {
+	if len(post.Ballot) > 0 {
+		return post.Ballot
+	}
+	return []models.VoteQuestion{post.VoteQuestions}
+}
+
+// voteChoices returns the choices of a vote, turning the single answer of
+// older votes into a choice for the first question
+func voteChoices(vote models.AVote) []models.VoteChoice {
+	if len(vote.Answers) > 0 {
+		return vote.Answers
+	}
+	if vote.Answer == "" {
+		return nil
+	}
+	return []models.VoteChoice{{QuestionIndex: 0, Choices: []string{vote.Answer}}}
+}
+
+// firstChoice is the top choice for the first question, stored as the answer
+// of the vote for clients that only know single-question votes
+func firstChoice(choices []models.VoteChoice) string {
+	for _, choice := range choices {
+		if choice.QuestionIndex == 0 && len(choice.Choices) > 0 {
+			return choice.Choices[0]
+		}
+	}
+	return ""
+}
+
+// validateVoteQuestions checks the ballot of a vote post when it is saved
+func validateVoteQuestions(post models.Post) string {
+	for i, question := range ballotQuestions(post) {
+		switch question.Method {
+		case "", models.VoteSingle, models.VoteApproval:
+		case models.VoteRanked:
+			if len(question.Options) < 2 {
+				return fmt.Sprintf("question %d: ranked votes need at least 2 options", i)
+			}
+		default:
+			return fmt.Sprintf("question %d: unknown voting method %q", i, question.Method)
+		}
+		if question.MaxSel < 0 {
+			return fmt.Sprintf("question %d: maxSel must not be negative", i)
+		}
+
+		seen := make(map[string]bool)
+		for _, option := range question.Options {
+			if seen[option] {
+				return fmt.Sprintf("question %d: option %q is listed twice", i, option)
+			}
+			seen[option] = true
+		}
+	}
+	return ""
+}
+
+type voteOptionCount struct {
+	Option string `json:"option"`
+	Count  int    `json:"count"`
+}
+
+// irvRound is one counting round of an instant-runoff vote
+type irvRound struct {
+	Round      int               `json:"round"`
+	Counts     []voteOptionCount `json:"counts"`
+	Exhausted  int               `json:"exhausted"` // Ballots without a continuing option left
+	Eliminated []string          `json:"eliminated,omitempty"`
+}
+
+type voteQuestionResult struct {
+	QuestionIndex int               `json:"questionIndex"`
+	Question      string            `json:"question"`
+	Method        string            `json:"method"`
+	TotalBallots  int               `json:"totalBallots"`
+	Results       []voteOptionCount `json:"results"` // First preferences for ranked questions
+	Rounds        []irvRound        `json:"rounds,omitempty"`
+	Winners       []string          `json:"winners"`
+}
+
+// loadVoteChoices reads the choices of every vote cast on a post
+func loadVoteChoices(ctx context.Context, post models.Post) ([][]models.VoteChoice, error) {
+	collection := transactionCollection
+	if post.Anonymous {
+		collection = ballotCollection
+		// Once the vote is over every ballot counts, however small its batch
+		if postClosed(post) {
+			if err := releaseBallots(ctx, post.ID, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"postID": post.ID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// Ballots have a string ID, so only the choices are decoded
+	var votes []struct {
+		Answer  string              `bson:"answer"`
+		Answers []models.VoteChoice `bson:"answers"`
+	}
+	if err := cursor.All(ctx, &votes); err != nil {
+		return nil, err
+	}
+
+	choices := make([][]models.VoteChoice, 0, len(votes))
+	for _, vote := range votes {
+		choices = append(choices, voteChoices(models.AVote{Answer: vote.Answer, Answers: vote.Answers}))
+	}
+	return choices, nil
+}
+
+// tallyVoteQuestion counts the votes for one question of the ballot
+func tallyVoteQuestion(index int, question models.VoteQuestion, votes [][]models.VoteChoice) voteQuestionResult {
+	method := question.Method
+	if method == "" {
+		method = models.VoteSingle
+	}
+	result := voteQuestionResult{QuestionIndex: index, Question: question.Question, Method: method, Winners: []string{}}
+
+	// Only count the options of the post, older answers may hold anything
+	var rankings [][]string
+	for _, vote := range votes {
+		for _, choice := range vote {
+			if choice.QuestionIndex != index {
+				continue
+			}
+			if ranking := validChoices(question.Options, choice.Choices); len(ranking) > 0 {
+				rankings = append(rankings, ranking)
+			}
+			break
+		}
+	}
+	result.TotalBallots = len(rankings)
+
+	counts := make(map[string]int)
+	for _, ranking := range rankings {
+		if method == models.VoteApproval {
+			for _, option := range ranking {
+				counts[option]++
+			}
+		} else {
+			counts[ranking[0]]++
+		}
+	}
+	result.Results = optionCounts(question.Options, counts)
+
+	if method == models.VoteRanked {
+		result.Rounds, result.Winners = instantRunoff(question.Options, rankings)
+		return result
+	}
+
+	best := 0
+	for _, count := range result.Results {
+		if count.Count > best {
+			best = count.Count
+			result.Winners = result.Winners[:0]
+		}
+		if best > 0 && count.Count == best {
+			result.Winners = append(result.Winners, count.Option)
+		}
+	}
+	return result
+}
+
+// validChoices drops unknown and repeated options, keeping the order
+func validChoices(options []string, choices []string) []string {
+	var valid []string
+	seen := make(map[string]bool)
+	for _, option := range choices {
+		if containsString(options, option) && !seen[option] {
+			valid = append(valid, option)
+			seen[option] = true
+		}
+	}
+	return valid
+}
+
+func optionCounts(options []string, counts map[string]int) []voteOptionCount {
+	result := make([]voteOptionCount, 0, len(options))
+	for _, option := range options {
+		result = append(result, voteOptionCount{Option: option, Count: counts[option]})
+	}
+	return result
+}
+
+// instantRunoff counts ranked ballots. Each round every ballot counts for its
+// highest ranked option still in the race. An option with a majority of the
+// counted ballots wins, otherwise the option with the fewest votes is
+// eliminated, see irvEliminated. When every remaining option is tied they
+// all win.
+func instantRunoff(options []string, rankings [][]string) ([]irvRound, []string) {
+	rounds := []irvRound{}
+	continuing := append([]string{}, options...)
+
+	for len(continuing) > 0 {
+		round := irvRound{Round: len(rounds) + 1}
+		counts := make(map[string]int)
+		for _, ranking := range rankings {
+			counted := false
+			for _, option := range ranking {
+				if containsString(continuing, option) {
+					counts[option]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				round.Exhausted++
+			}
+		}
+		round.Counts = optionCounts(continuing, counts)
+
+		active := len(rankings) - round.Exhausted
+		if active == 0 {
+			rounds = append(rounds, round)
+			return rounds, []string{}
+		}
+
+		tied := true
+		for _, option := range continuing {
+			if counts[option]*2 > active {
+				rounds = append(rounds, round)
+				return rounds, []string{option}
+			}
+			if counts[option] != counts[continuing[0]] {
+				tied = false
+			}
+		}
+		if tied {
+			rounds = append(rounds, round)
+			return rounds, continuing
+		}
+
+		round.Eliminated = irvEliminated(continuing, counts, rounds)
+		rounds = append(rounds, round)
+
+		var remaining []string
+		for _, option := range continuing {
+			if !containsString(round.Eliminated, option) {
+				remaining = append(remaining, option)
+			}
+		}
+		continuing = remaining
+	}
+
+	return rounds, []string{}
+}
+
+// irvEliminated picks the option to drop after a round without a winner, the
+// one with the fewest votes. A tie for the fewest goes against the option
+// with fewer votes in the latest earlier round where the tied options differ,
+// and failing that against the one listed last on the ballot.
+func irvEliminated(continuing []string, counts map[string]int, previous []irvRound) []string {
+	// Fewest votes first, ties in the order of elimination
+	order := append([]string{}, continuing...)
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if counts[a] != counts[b] {
+			return counts[a] < counts[b]
+		}
+		for r := len(previous) - 1; r >= 0; r-- {
+			ca, cb := roundCount(previous[r], a), roundCount(previous[r], b)
+			if ca != cb {
+				return ca < cb
+			}
+		}
+		return slices.Index(continuing, a) > slices.Index(continuing, b)
+	})
+	return order[:1]
+}
+
+func roundCount(round irvRound, option string) int {
+	for _, count := range round.Counts {
+		if count.Option == option {
+			return count.Count
+		}
+	}
+	return 0
+}
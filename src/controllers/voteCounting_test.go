@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	models "github.com/encall/cpeevent-backend/src/models"
+)
+
+func repeatRanking(n int, ranking ...string) [][]string {
+	rankings := make([][]string, n)
+	for i := range rankings {
+		rankings[i] = ranking
+	}
+	return rankings
+}
+
+func concatRankings(groups ...[][]string) [][]string {
+	var rankings [][]string
+	for _, group := range groups {
+		rankings = append(rankings, group...)
+	}
+	return rankings
+}
+
+func TestInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []string
+		rankings   [][]string
+		eliminated [][]string
+		winners    []string
+	}{
+		{
+			name:    "majority in the first round",
+			options: []string{"A", "B", "C"},
+			rankings: concatRankings(
+				repeatRanking(3, "A"),
+				repeatRanking(1, "B"),
+				repeatRanking(1, "C"),
+			),
+			eliminated: [][]string{nil},
+			winners:    []string{"A"},
+		},
+		{
+			name:    "one of two tied options is eliminated",
+			options: []string{"A", "B", "C"},
+			rankings: concatRankings(
+				repeatRanking(3, "A"),
+				repeatRanking(2, "B", "C"),
+				repeatRanking(2, "C", "B"),
+			),
+			eliminated: [][]string{{"C"}, nil},
+			winners:    []string{"B"},
+		},
+		{
+			name:    "tie broken by the earlier round",
+			options: []string{"A", "B", "C", "D"},
+			rankings: concatRankings(
+				repeatRanking(4, "A"),
+				repeatRanking(2, "B", "C"),
+				repeatRanking(3, "C"),
+				repeatRanking(1, "D", "B", "C"),
+			),
+			eliminated: [][]string{{"D"}, {"B"}, nil},
+			winners:    []string{"C"},
+		},
+		{
+			name:    "options that cannot catch up are eliminated one per round",
+			options: []string{"A", "B", "C", "D"},
+			rankings: concatRankings(
+				repeatRanking(5, "A"),
+				repeatRanking(4, "B"),
+				repeatRanking(1, "C", "B"),
+				repeatRanking(1, "D", "B"),
+			),
+			eliminated: [][]string{{"D"}, {"C"}, nil},
+			winners:    []string{"B"},
+		},
+		{
+			name:    "every remaining option tied",
+			options: []string{"A", "B"},
+			rankings: concatRankings(
+				repeatRanking(2, "A"),
+				repeatRanking(2, "B"),
+			),
+			eliminated: [][]string{nil},
+			winners:    []string{"A", "B"},
+		},
+		{
+			name:       "every ballot exhausted",
+			options:    []string{"A", "B"},
+			rankings:   [][]string{{}, {}},
+			eliminated: [][]string{nil},
+			winners:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounds, winners := instantRunoff(tt.options, tt.rankings)
+			if !reflect.DeepEqual(winners, tt.winners) {
+				t.Errorf("winners = %v, want %v", winners, tt.winners)
+			}
+			var eliminated [][]string
+			for _, round := range rounds {
+				eliminated = append(eliminated, round.Eliminated)
+			}
+			if !reflect.DeepEqual(eliminated, tt.eliminated) {
+				t.Errorf("eliminated = %v, want %v", eliminated, tt.eliminated)
+			}
+		})
+	}
+}
+
+func TestVoteChoices(t *testing.T) {
+	tests := []struct {
+		name string
+		vote models.AVote
+		want []models.VoteChoice
+	}{
+		{"multi-question vote", models.AVote{Answer: "A", Answers: []models.VoteChoice{{QuestionIndex: 1, Choices: []string{"X"}}}}, []models.VoteChoice{{QuestionIndex: 1, Choices: []string{"X"}}}},
+		{"single answer of an older vote", models.AVote{Answer: "A"}, []models.VoteChoice{{QuestionIndex: 0, Choices: []string{"A"}}}},
+		{"no answer", models.AVote{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := voteChoices(tt.vote); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("voteChoices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVoteQuestions(t *testing.T) {
+	tests := []struct {
+		name   string
+		ballot []models.VoteQuestion
+		want   string
+	}{
+		{"valid", []models.VoteQuestion{{Options: []string{"A", "B"}}, {Options: []string{"X", "Y"}, Method: models.VoteRanked}}, ""},
+		{"ranked with one option", []models.VoteQuestion{{Options: []string{"A"}, Method: models.VoteRanked}}, "question 0: ranked votes need at least 2 options"},
+		{"unknown method", []models.VoteQuestion{{Options: []string{"A"}, Method: "borda"}}, `question 0: unknown voting method "borda"`},
+		{"negative maxSel", []models.VoteQuestion{{Options: []string{"A"}, Method: models.VoteApproval, MaxSel: -1}}, "question 0: maxSel must not be negative"},
+		{"repeated option", []models.VoteQuestion{{Options: []string{"A"}}, {Options: []string{"B", "B"}}}, `question 1: option "B" is listed twice`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateVoteQuestions(models.Post{Ballot: tt.ballot}); got != tt.want {
+				t.Errorf("validateVoteQuestions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTallyVoteQuestion(t *testing.T) {
+	vote := func(choices ...string) []models.VoteChoice {
+		return []models.VoteChoice{{QuestionIndex: 0, Choices: choices}}
+	}
+
+	tests := []struct {
+		name     string
+		question models.VoteQuestion
+		votes    [][]models.VoteChoice
+		total    int
+		counts   []int
+		winners  []string
+	}{
+		{
+			name:     "single choice",
+			question: models.VoteQuestion{Options: []string{"A", "B", "C"}},
+			votes:    [][]models.VoteChoice{vote("A"), vote("B"), vote("A"), vote("D")},
+			total:    3,
+			counts:   []int{2, 1, 0},
+			winners:  []string{"A"},
+		},
+		{
+			name:     "single choice tie",
+			question: models.VoteQuestion{Options: []string{"A", "B"}},
+			votes:    [][]models.VoteChoice{vote("A"), vote("B")},
+			total:    2,
+			counts:   []int{1, 1},
+			winners:  []string{"A", "B"},
+		},
+		{
+			name:     "approval",
+			question: models.VoteQuestion{Options: []string{"A", "B", "C"}, Method: models.VoteApproval},
+			votes:    [][]models.VoteChoice{vote("A", "B"), vote("B", "C"), vote("B", "B")},
+			total:    3,
+			counts:   []int{1, 3, 1},
+			winners:  []string{"B"},
+		},
+		{
+			name:     "ranked counts first preferences",
+			question: models.VoteQuestion{Options: []string{"A", "B", "C"}, Method: models.VoteRanked},
+			votes:    [][]models.VoteChoice{vote("A"), vote("A"), vote("B", "C"), vote("C", "B"), vote("C", "B")},
+			total:    5,
+			counts:   []int{2, 1, 2},
+			winners:  []string{"C"},
+		},
+		{
+			name:     "no votes",
+			question: models.VoteQuestion{Options: []string{"A"}},
+			total:    0,
+			counts:   []int{0},
+			winners:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tallyVoteQuestion(0, tt.question, tt.votes)
+			if result.TotalBallots != tt.total {
+				t.Errorf("TotalBallots = %d, want %d", result.TotalBallots, tt.total)
+			}
+			var counts []int
+			for _, count := range result.Results {
+				counts = append(counts, count.Count)
+			}
+			if !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+			if !reflect.DeepEqual(result.Winners, tt.winners) {
+				t.Errorf("Winners = %v, want %v", result.Winners, tt.winners)
+			}
+		})
+	}
+}
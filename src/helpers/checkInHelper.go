@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// CheckInDetails are the claims inside a check-in QR code
+type CheckInDetails struct {
+	StudentID string
+	EventID   string
+	jwt.StandardClaims
+}
+
+// Check-in tokens use their own key so they can never pass as a login token
+var checkInKey = []byte(SECRET_KEY + ":checkin")
+
+// CheckInTokenTTL is how long a check-in QR code stays valid
+var CheckInTokenTTL = checkInTTL()
+
+func checkInTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("CHECKIN_TOKEN_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 10
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateCheckInToken signs a short-lived token for a member of an event
+func GenerateCheckInToken(studentID string, eventID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(CheckInTokenTTL)
+	claims := &CheckInDetails{
+		StudentID: studentID,
+		EventID:   eventID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(checkInKey)
+	return token, expiresAt, err
+}
+
+// ValidateCheckInToken verifies the signature and expiry of a check-in token
+func ValidateCheckInToken(signedToken string) (*CheckInDetails, error) {
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&CheckInDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return checkInKey, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*CheckInDetails)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid check-in token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,20 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EscapeFormula keeps a CSV cell from being run as a formula when the file
+// is opened in a spreadsheet, by prefixing cells that start like one with a
+// quote. Plain numbers such as "-5" are left alone. XLSX text cells need no
+// escaping, they are never evaluated.
+func EscapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
@@ -0,0 +1,27 @@
+package helper
+
+import "testing"
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+1+1", "'+1+1"},
+		{"-2+3+cmd|' /C calc'!A0", "'-2+3+cmd|' /C calc'!A0"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"-5", "-5"},
+		{"+3.25", "+3.25"},
+		{"a=b", "a=b"},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeFormula(tt.cell); got != tt.want {
+			t.Errorf("EscapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
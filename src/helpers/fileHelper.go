@@ -0,0 +1,69 @@
+package helper
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// FileDetails are the claims of a download link
+type FileDetails struct {
+	FileID string
+	jwt.StandardClaims
+}
+
+// Download links use their own key so they can never pass as a login token
+var fileKey = []byte(SECRET_KEY + ":file")
+
+// FileLinkTTL is how long a download link stays valid
+var FileLinkTTL = fileLinkTTL()
+
+func fileLinkTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("FILE_LINK_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateFileToken signs a short-lived token that grants the download of one file
+func GenerateFileToken(fileID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(FileLinkTTL)
+	claims := &FileDetails{
+		FileID: fileID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(fileKey)
+	return token, expiresAt, err
+}
+
+// ValidateFileToken verifies the signature and expiry of a download link
+func ValidateFileToken(signedToken string) (*FileDetails, error) {
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&FileDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return fileKey, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*FileDetails)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid download link")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,109 @@
+package helper
+
+import (
+	"strings"
+	"time"
+)
+
+// ICalEvent is a VEVENT entry of an iCalendar feed
+type ICalEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Status      string // TENTATIVE, CONFIRMED or CANCELLED, optional
+}
+
+// ICalTodo is a VTODO entry of an iCalendar feed
+type ICalTodo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// BuildICalendar renders an RFC 5545 calendar
+func BuildICalendar(name string, events []ICalEvent, todos []ICalTodo) string {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//CPEEVO//Event Calendar//EN")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(name))
+
+	for _, event := range events {
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+event.UID)
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART:"+event.Start.UTC().Format(icalTimeFormat))
+		// Without DTEND the event is an instant, e.g. a deadline
+		if event.End.After(event.Start) {
+			writeICalLine(&b, "DTEND:"+event.End.UTC().Format(icalTimeFormat))
+		}
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(event.Summary))
+		if event.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(event.Description))
+		}
+		if event.Status != "" {
+			writeICalLine(&b, "STATUS:"+event.Status)
+		}
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	for _, todo := range todos {
+		writeICalLine(&b, "BEGIN:VTODO")
+		writeICalLine(&b, "UID:"+todo.UID)
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DUE:"+todo.Due.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(todo.Summary))
+		if todo.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(todo.Description))
+		}
+		writeICalLine(&b, "END:VTODO")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// escapeICalText escapes a TEXT value (RFC 5545 section 3.3.11)
+func escapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(text)
+}
+
+// writeICalLine writes a content line folded at 75 octets without splitting
+// a UTF-8 sequence (RFC 5545 section 3.1)
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards the limit
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isUTF8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
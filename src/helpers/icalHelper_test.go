@@ -0,0 +1,104 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeICalText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Camp", "Camp"},
+		{"Food; drinks, games", `Food\; drinks\, games`},
+		{`C:\temp`, `C:\\temp`},
+		{"line 1\r\nline 2\nline 3\rline 4", `line 1\nline 2\nline 3\nline 4`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeICalText(tt.text); got != tt.want {
+			t.Errorf("escapeICalText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWriteICalLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Camp"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"long ascii", "DESCRIPTION:" + strings.Repeat("abcdefghij", 20)},
+		{"long thai", "SUMMARY:" + strings.Repeat("ค่ายอาสา", 12)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeICalLine(&b, tt.line)
+			out := b.String()
+
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("line does not end with CRLF: %q", out)
+			}
+			parts := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			unfolded := parts[0]
+			for i, part := range parts {
+				if len(part) > 75 {
+					t.Errorf("line %d is %d octets long", i, len(part))
+				}
+				if !utf8.ValidString(part) {
+					t.Errorf("line %d splits a character: %q", i, part)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(part, " ") {
+						t.Errorf("continuation line %d does not start with a space", i)
+					}
+					unfolded += part[1:]
+				}
+			}
+			if unfolded != tt.line {
+				t.Errorf("unfolded line = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestBuildICalendar(t *testing.T) {
+	start := time.Date(2024, 8, 10, 2, 0, 0, 0, time.UTC)
+	events := []ICalEvent{
+		{UID: "1@cpeevo", Summary: "Camp, day 1", Description: "Bring water", Start: start, End: start.Add(3 * time.Hour), Status: "CONFIRMED"},
+		{UID: "2@cpeevo", Summary: "Deadline", Start: start, End: start},
+	}
+	todos := []ICalTodo{{UID: "3@cpeevo", Summary: "Form", Due: start.In(time.FixedZone("ICT", 7*3600))}}
+
+	ical := BuildICalendar("CPE; events", events, todos)
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:CPE\\; events\r\n",
+		"BEGIN:VEVENT\r\nUID:1@cpeevo\r\n",
+		"DTSTART:20240810T020000Z\r\nDTEND:20240810T050000Z\r\nSUMMARY:Camp\\, day 1\r\nDESCRIPTION:Bring water\r\nSTATUS:CONFIRMED\r\nEND:VEVENT\r\n",
+		"UID:2@cpeevo\r\n",
+		"BEGIN:VTODO\r\nUID:3@cpeevo\r\n",
+		"DUE:20240810T020000Z\r\nSUMMARY:Form\r\nEND:VTODO\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("calendar does not contain %q\n%s", want, ical)
+		}
+	}
+	if !strings.HasSuffix(ical, "END:VCALENDAR\r\n") {
+		t.Errorf("calendar does not end with END:VCALENDAR")
+	}
+
+	// An event without length has no DTEND
+	second := ical[strings.Index(ical, "UID:2@cpeevo"):]
+	second = second[:strings.Index(second, "END:VEVENT")]
+	if strings.Contains(second, "DTEND") {
+		t.Errorf("instant event has a DTEND:\n%s", second)
+	}
+}
@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxImagePixels bounds the decoded size of an upload, a small file can
+// still describe a huge image
+const MaxImagePixels = 40_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a PNG, JPEG, GIF or WebP")
+	ErrImageTooLarge    = errors.New("image has too many pixels")
+)
+
+// DecodeImage checks the dimensions of an image before decoding it and
+// returns the image with its format name
+func DecodeImage(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, "", ErrUnsupportedImage
+	}
+	if config.Width*config.Height > MaxImagePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	return img, format, nil
+}
+
+// Thumbnail scales an image down so its longest side is at most maxSide,
+// smaller images are returned as they are
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxSide/width)
+		width = maxSide
+	} else {
+		width = max(1, width*maxSide/height)
+		height = maxSide
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+	return thumb
+}
+
+// EncodeImage writes an image as PNG when it may be transparent and as JPEG
+// otherwise, and returns the bytes with their content type
+func EncodeImage(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "png", "gif", "webp":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	default:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+}
+
+// ImageContentType is the MIME type of a format returned by DecodeImage
+func ImageContentType(format string) string {
+	switch format {
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "webp":
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
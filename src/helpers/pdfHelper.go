@@ -0,0 +1,23 @@
+package helper
+
+import (
+	_ "embed"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDFFont is the font family NewPDF registers. Unlike the core PDF fonts it
+// covers Thai as well as Latin.
+const PDFFont = "FreeSerif"
+
+//go:embed fonts/FreeSerif.ttf
+var pdfFontData []byte
+
+// NewPDF starts an A4 portrait document that writes UTF-8 text in PDFFont
+func NewPDF() *fpdf.Fpdf {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(PDFFont, "", pdfFontData)
+	// There is no bold cut, bold text is set in the regular one
+	pdf.AddUTF8FontFromBytes(PDFFont, "B", pdfFontData)
+	return pdf
+}
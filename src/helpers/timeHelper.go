@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"log"
+	"os"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+)
+
+// The frontend sends event and post dates as wall-clock times of the event
+// timezone labelled as UTC. These helpers convert between that clock and
+// real instants, so deadlines are compared in UTC wherever the server runs.
+
+// EventLocation is the timezone events take place in, EVENT_TIMEZONE
+var EventLocation = loadEventLocation()
+
+func loadEventLocation() *time.Location {
+	name := os.Getenv("EVENT_TIMEZONE")
+	if name == "" {
+		name = "Asia/Bangkok"
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown EVENT_TIMEZONE %q, using UTC", name)
+		return time.UTC
+	}
+	return location
+}
+
+// FromEventClock returns the instant a stored wall-clock time refers to
+func FromEventClock(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), EventLocation).UTC()
+}
+
+// ToEventClock returns the wall-clock time of an instant as it is stored
+func ToEventClock(t time.Time) time.Time {
+	local := t.In(EventLocation)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,126 @@
+package helper
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, tt := range tests {
+		if got := xlsxColumn(tt.index); got != tt.want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestIsXLSXNumber(t *testing.T) {
+	tests := []struct {
+		cell string
+		want bool
+	}{
+		{"42", true},
+		{"-1.5", true},
+		{"007", false},
+		{"1e5", false},
+		{"1.50", false},
+		{"NaN", false},
+		{"+Inf", false},
+		{"", false},
+		{"12 apples", false},
+	}
+
+	for _, tt := range tests {
+		if got := isXLSXNumber(tt.cell); got != tt.want {
+			t.Errorf("isXLSXNumber(%q) = %v, want %v", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Answers", "Answers"},
+		{"Q1/Q2: [draft]?", "Q1Q2 draft"},
+		{"", "Sheet1"},
+		{"/*?", "Sheet1"},
+		{strings.Repeat("แบบ", 20), strings.Repeat("แบบ", 10) + "แ"},
+	}
+
+	for _, tt := range tests {
+		if got := xlsxSheetName(tt.name); got != tt.want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "Answers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{
+		{"Name", "Score"},
+		{"<Somchai & co>", "12.5"},
+		{"=HYPERLINK(\"x\")", "007"},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sheet = string(data)
+	}
+
+	for _, want := range []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">Name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">&lt;Somchai &amp; co&gt;</t></is></c><c r="B2"><v>12.5</v></c>`,
+		`<c r="A3" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;x&#34;)</t></is></c>`,
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">007</t></is></c>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet does not contain %s\n%s", want, sheet)
+		}
+	}
+}
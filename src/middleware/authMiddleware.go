@@ -61,3 +61,19 @@ func Authentication(requiredAccessLevel int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthentication identifies the user when a valid token is sent but
+// lets anonymous requests through
+func OptionalAuthentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientToken := c.Request.Header.Get("Authorization")
+		if len(clientToken) > 7 && clientToken[:7] == "Bearer " {
+			claims, msg := helper.ValidateToken(clientToken[7:])
+			if msg == "" {
+				c.Set("studentid", claims.StudentID)
+				c.Set("access", claims.Access)
+			}
+		}
+		c.Next()
+	}
+}
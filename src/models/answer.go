@@ -1,9 +1,26 @@
 package models
 
 import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Status of an answer. Answers stored without a status were submitted.
+const (
+	AnswerStatusDraft     = "draft"
+	AnswerStatusSubmitted = "submitted"
+)
+
+// AnswerMeta holds the bookkeeping shared by every kind of answer
+type AnswerMeta struct {
+	Status      string    `bson:"status,omitempty" json:"status,omitempty"` // Drafts are saved without validation and only seen by their owner
+	SubmittedAt time.Time `bson:"submittedAt" json:"submittedAt"`
+	UpdatedAt   time.Time `bson:"updatedAt" json:"updatedAt"`
+	Version     int       `bson:"version" json:"version"`
+}
+
 type AQuestion struct {
 	QuestionIndex int      `bson:"questionIndex" json:"questionIndex"`
 	InputType     string   `bson:"inputType" json:"inputType"`
@@ -15,11 +32,82 @@ type AForm struct {
 	PostID     primitive.ObjectID `bson:"postID" json:"postID"`
 	StudentID  string             `bson:"studentID" json:"studentID"`
 	AnswerList []AQuestion        `bson:"answerList" json:"answerList"`
+	Score      *QuizScore         `bson:"score,omitempty" json:"score,omitempty"` // Set for quizzes, shown to the student after the deadline
+	AnswerMeta `bson:",inline"`
+
+	// Version of the form questions it answers, 0 counts as 1
+	QuestionsVersion int `bson:"questionsVersion,omitempty" json:"questionsVersion,omitempty"`
+}
+
+// QuizScore is the grade of a quiz submission
+type QuizScore struct {
+	Points    float64         `bson:"points" json:"points"`
+	MaxPoints float64         `bson:"maxPoints" json:"maxPoints"`
+	Questions []QuestionScore `bson:"questions" json:"questions"`
+}
+
+type QuestionScore struct {
+	QuestionIndex int     `bson:"questionIndex" json:"questionIndex"`
+	Correct       bool    `bson:"correct" json:"correct"`
+	Points        float64 `bson:"points" json:"points"`
 }
 
 type AVote struct {
-	ID        primitive.ObjectID `bson:"_id" json:"_id"`
-	PostID    primitive.ObjectID `bson:"postID" json:"postID"`
-	StudentID string             `bson:"studentID" json:"studentID"`
-	Answer    string             `bson:"answer" json:"answer"`
+	ID         primitive.ObjectID `bson:"_id" json:"_id"`
+	PostID     primitive.ObjectID `bson:"postID" json:"postID"`
+	StudentID  string             `bson:"studentID" json:"studentID"`
+	Answer     string             `bson:"answer" json:"answer"`                       // Choice for the first question, kept for older clients
+	Answers    []VoteChoice       `bson:"answers,omitempty" json:"answers,omitempty"` // Choices for every question of the ballot
+	AnswerMeta `bson:",inline"`
+}
+
+// VoteChoice holds the options chosen for one vote question. For ranked
+// questions they are in order of preference.
+type VoteChoice struct {
+	QuestionIndex int      `bson:"questionIndex" json:"questionIndex"`
+	Choices       []string `bson:"choices" json:"choices"`
+}
+
+// Actions recorded in the answer history
+const (
+	AnswerActionEdit        = "edit"
+	AnswerActionWithdraw    = "withdraw"
+	AnswerActionDeduplicate = "deduplicate"
+)
+
+// AnswerRevision is a previous version of an answer, kept for audit
+type AnswerRevision struct {
+	ID         primitive.ObjectID `bson:"_id" json:"_id"`
+	AnswerID   primitive.ObjectID `bson:"answerID" json:"answerID"`
+	PostID     primitive.ObjectID `bson:"postID" json:"postID"`
+	StudentID  string             `bson:"studentID" json:"studentID"`
+	Version    int                `bson:"version" json:"version"`
+	Action     string             `bson:"action" json:"action"`
+	Answer     bson.Raw           `bson:"answer" json:"answer"`
+	ArchivedAt time.Time          `bson:"archivedAt" json:"archivedAt"`
+}
+
+// Codes of AnswerError
+const (
+	AnswerErrUnknownQuestion   = "UNKNOWN_QUESTION"
+	AnswerErrDuplicateQuestion = "DUPLICATE_QUESTION"
+	AnswerErrWrongInputType    = "WRONG_INPUT_TYPE"
+	AnswerErrMissingAnswer     = "MISSING_ANSWER"
+	AnswerErrInvalidOption     = "INVALID_OPTION"
+	AnswerErrDuplicateOption   = "DUPLICATE_OPTION"
+	AnswerErrTooManySelections = "TOO_MANY_SELECTIONS"
+	AnswerErrNotANumber        = "NOT_A_NUMBER"
+	AnswerErrTooManyAnswers    = "TOO_MANY_ANSWERS"
+	AnswerErrTooFewSelections  = "TOO_FEW_SELECTIONS"
+	AnswerErrOutOfRange        = "OUT_OF_RANGE"
+	AnswerErrTooShort          = "TOO_SHORT"
+	AnswerErrTooLong           = "TOO_LONG"
+	AnswerErrInvalidFile       = "INVALID_FILE"
+)
+
+// AnswerError describes why one question of a submission was rejected
+type AnswerError struct {
+	QuestionIndex int    `json:"questionIndex"`
+	Code          string `json:"code"`
+	Message       string `json:"message"`
 }
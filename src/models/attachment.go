@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a file organizers add to a post. It can be read by whoever
+// can read the post.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id" json:"_id"`
+	PostID      primitive.ObjectID `bson:"postID" json:"postID"`
+	EventID     primitive.ObjectID `bson:"eventID" json:"eventID"` // Quotas are counted per event
+	Key         string             `bson:"key" json:"-"`           // Location in the storage backend
+	PreviewKey  string             `bson:"previewKey,omitempty" json:"-"`
+	PreviewType string             `bson:"previewType,omitempty" json:"-"`
+	PreviewSize int64              `bson:"previewSize,omitempty" json:"-"`
+	HasPreview  bool               `bson:"hasPreview" json:"hasPreview"` // Images get a downscaled preview
+	FileName    string             `bson:"fileName" json:"fileName"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width,omitempty" json:"width,omitempty"` // Of images
+	Height      int                `bson:"height,omitempty" json:"height,omitempty"`
+	UploadedBy  string             `bson:"uploadedBy" json:"uploadedBy"`
+	UploadedAt  time.Time          `bson:"uploadedAt" json:"uploadedAt"`
+}
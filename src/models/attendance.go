@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CheckIn records that a member of an event was scanned in
+type CheckIn struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	EventID     primitive.ObjectID `bson:"eventID" json:"eventID"`
+	StudentID   string             `bson:"studentID" json:"studentID"`
+	Role        string             `bson:"role" json:"role"` // "participant" or "staff"
+	CheckedInAt time.Time          `bson:"checkedInAt" json:"checkedInAt"`
+	ScannedBy   string             `bson:"scannedBy" json:"scannedBy"`
+}
+
+// AttendanceEntry is one registered member in an attendance report
+type AttendanceEntry struct {
+	StdID       string     `bson:"stdID" json:"stdID"`
+	Name        string     `bson:"name" json:"name"`
+	Role        string     `bson:"role,omitempty" json:"role,omitempty"`
+	Attended    bool       `bson:"attended" json:"attended"`
+	CheckedInAt *time.Time `bson:"checkedInAt" json:"checkedInAt"`
+}
@@ -15,6 +15,7 @@ type User struct {
 	Access        int       `json:"access" bson:"access"`
 	Token         *string   `json:"token" bson:"token"`
 	Refresh_token *string   `json:"refresh_token" bson:"refresh_token"`
+	CalendarToken *string   `json:"-" bson:"calendarToken"` // Personal iCalendar feed, only sent by GetCalendarToken
 	Created_at    time.Time `json:"created_at" bson:"created_at"`
 	Updated_at    time.Time `json:"updated_at" bson:"updated_at"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Participation records that a student voted in an anonymous vote, without
+// what they voted for
+type Participation struct {
+	ID        primitive.ObjectID `bson:"_id" json:"_id"`
+	PostID    primitive.ObjectID `bson:"postID" json:"postID"`
+	StudentID string             `bson:"studentID" json:"studentID"`
+	VotedAt   time.Time          `bson:"votedAt" json:"votedAt"`
+}
+
+// Ballot is an anonymous vote. It holds no student, no timestamp and a random
+// ID, so it cannot be matched with the participation ledger.
+type Ballot struct {
+	ID          string             `bson:"_id" json:"_id"`
+	PostID      primitive.ObjectID `bson:"postID" json:"postID"`
+	Answer      string             `bson:"answer" json:"answer"`
+	Answers     []VoteChoice       `bson:"answers,omitempty" json:"answers,omitempty"`
+	ReceiptHash string             `bson:"receiptHash" json:"-"` // SHA-256 of the receipt handed to the voter
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a message in the discussion of a post. Replies point to the
+// comment they answer, top level comments have no parent.
+type Comment struct {
+	ID        primitive.ObjectID  `bson:"_id" json:"_id"`
+	PostID    primitive.ObjectID  `bson:"postID" json:"postID"`
+	ParentID  *primitive.ObjectID `bson:"parentID,omitempty" json:"parentID,omitempty"`
+	AuthorID  string              `bson:"authorID" json:"authorID"`
+	Body      string              `bson:"body" json:"body"`
+	Mentions  []string            `bson:"mentions,omitempty" json:"mentions,omitempty"` // Student IDs written as @studentID
+	Hidden    bool                `bson:"hidden,omitempty" json:"hidden,omitempty"`     // By a moderator, the body is only shown to moderators
+	HiddenBy  string              `bson:"hiddenBy,omitempty" json:"hiddenBy,omitempty"`
+	Deleted   bool                `bson:"deleted,omitempty" json:"deleted,omitempty"` // By the author, kept so replies stay in their thread
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	EditedAt  *time.Time          `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+}
@@ -12,21 +12,47 @@ type StaffMember struct {
 	Role  string `json:"role" bson:"role"`
 }
 
+// WaitlistEntry is a student queued for a full event
+type WaitlistEntry struct {
+	StdID    string    `json:"stdID" bson:"stdID"`
+	Role     string    `json:"role" bson:"role"`       // "participant" or "staff"
+	SubRole  string    `json:"subRole" bson:"subRole"` // staff role, if any
+	JoinedAt time.Time `json:"joinedAt" bson:"joinedAt"`
+}
+
+// Event lifecycle states, in the order an event moves through them
+const (
+	EventStatusDraft              = "draft"
+	EventStatusPublished          = "published"
+	EventStatusRegistrationOpen   = "registration_open"
+	EventStatusRegistrationClosed = "registration_closed"
+	EventStatusRunning            = "running"
+	EventStatusFinished           = "finished"
+	EventStatusArchived           = "archived"
+)
+
 // Define the Event struct with updated types
 type Event struct {
-	ID               primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
-	EventName        string               `json:"eventName" bson:"eventName" binding:"required"`
-	EventDescription string               `json:"eventDescription" bson:"eventDescription"`
-	NParticipant     *int                 `json:"nParticipant" bson:"nParticipant"`
-	Participants     []string             `json:"participants" bson:"participants"`
-	NStaff           *int                 `json:"nStaff" bson:"nStaff"`
-	Staff            []StaffMember        `json:"staff" bson:"staff"`
-	StartDate        time.Time            `json:"startDate" bson:"startDate"`
-	EndDate          time.Time            `json:"endDate" bson:"endDate"`
-	President        *string              `json:"president" bson:"president"`
-	Kind             string               `json:"kind" bson:"kind"`
-	Role             []string             `json:"role" bson:"role"`
-	Icon             *string              `json:"icon" bson:"icon"`
-	Poster           *string              `json:"poster" bson:"poster"`
-	PostList         []primitive.ObjectID `json:"postList" bson:"postList"`
+	ID                primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	EventName         string               `json:"eventName" bson:"eventName" binding:"required"`
+	EventDescription  string               `json:"eventDescription" bson:"eventDescription"`
+	NParticipant      *int                 `json:"nParticipant" bson:"nParticipant"`
+	Participants      []string             `json:"participants" bson:"participants"`
+	NStaff            *int                 `json:"nStaff" bson:"nStaff"`
+	Staff             []StaffMember        `json:"staff" bson:"staff"`
+	Waitlist          []WaitlistEntry      `json:"-" bson:"waitlist"`                   // Only the president sees the queue, through GetWaitlist
+	WaitlistPosition  int                  `json:"waitlistPosition,omitempty" bson:"-"` // Of the student asking, set per request
+	StartDate         time.Time            `json:"startDate" bson:"startDate"`
+	EndDate           time.Time            `json:"endDate" bson:"endDate"`
+	Hours             *float64             `json:"hours" bson:"hours"`                 // Nullable, credited activity hours
+	AttachmentUsage   *int64               `json:"-" bson:"attachmentUsage,omitempty"` // Bytes taken by the attachments of its posts, nil until counted
+	Status            string               `json:"status" bson:"status"`
+	RegistrationOpen  *time.Time           `json:"registrationOpen" bson:"registrationOpen"`   // Nullable, open on publish
+	RegistrationClose *time.Time           `json:"registrationClose" bson:"registrationClose"` // Nullable, close on start
+	President         *string              `json:"president" bson:"president"`
+	Kind              string               `json:"kind" bson:"kind"`
+	Role              []string             `json:"role" bson:"role"`
+	Icon              *string              `json:"icon" bson:"icon"`
+	Poster            *string              `json:"poster" bson:"poster"`
+	PostList          []primitive.ObjectID `json:"postList" bson:"postList"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What a media item is used for, which decides the size of its thumbnail
+const (
+	MediaProfile = "profile"
+	MediaIcon    = "icon"
+	MediaPoster  = "poster"
+)
+
+// Media is an image kept in the storage backend, served from /media/:mediaID
+type Media struct {
+	ID          primitive.ObjectID `bson:"_id" json:"_id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	OwnerID     string             `bson:"ownerID" json:"ownerID"` // Student ID for profiles, event ID for icons and posters
+	Key         string             `bson:"key" json:"-"`
+	ThumbKey    string             `bson:"thumbKey" json:"-"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	ThumbType   string             `bson:"thumbType" json:"-"`
+	Width       int                `bson:"width" json:"width"`
+	Height      int                `bson:"height" json:"height"`
+	Size        int64              `bson:"size" json:"size"`
+	ThumbSize   int64              `bson:"thumbSize" json:"-"`
+	ETag        string             `bson:"etag" json:"-"` // Hash of the original, media is never changed in place
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of notification
+const (
+	NotificationPostPublished = "post_published"
+	NotificationMention       = "mention"
+	NotificationTaskOverdue   = "task_overdue"
+)
+
+// Notification tells one student about something that happened in an event
+type Notification struct {
+	ID        primitive.ObjectID  `bson:"_id" json:"_id"`
+	StudentID string              `bson:"studentID" json:"studentID"`
+	Kind      string              `bson:"kind" json:"kind"`
+	EventID   primitive.ObjectID  `bson:"eventID" json:"eventID"`
+	PostID    *primitive.ObjectID `bson:"postID,omitempty" json:"postID,omitempty"`
+	CommentID *primitive.ObjectID `bson:"commentID,omitempty" json:"commentID,omitempty"`
+	Title     string              `bson:"title" json:"title"`
+	Message   string              `bson:"message" json:"message"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	ReadAt    *time.Time          `bson:"readAt" json:"readAt"` // Nullable until read
+}
@@ -4,34 +4,123 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Voting methods of a vote question
+const (
+	VoteSingle   = "single" // default, one option
+	VoteApproval = "approval"
+	VoteRanked   = "ranked" // instant-runoff
+)
+
 // VoteQuestion represents a question in a vote post.
 type VoteQuestion struct {
 	Question string   `bson:"question,omitempty" json:"question,omitempty"`
 	Options  []string `bson:"options,omitempty" json:"options,omitempty"`
+	Method   string   `bson:"method,omitempty" json:"method,omitempty"` // One of the Vote methods
+	MaxSel   int      `bson:"maxSel,omitempty" json:"maxSel,omitempty"` // Approval limit, 0 for any number
+}
+
+// Input types of form questions understood by the server. Unknown types are
+// treated as choice questions when they have options and as text otherwise.
+const (
+	InputText     = "text"
+	InputTextArea = "textarea"
+	InputRadio    = "radio"
+	InputDropdown = "dropdown"
+	InputCheckbox = "checkbox"
+	InputNumber   = "number"
+	InputRating   = "rating"
+	InputFile     = "file" // Answered with the IDs of uploads
+)
+
+// Operators of a ShowIf condition
+const (
+	ShowIfEquals    = "equals"     // The answer is exactly Value
+	ShowIfNotEquals = "not_equals" // The answer is not Value, or there is none
+	ShowIfIncludes  = "includes"   // Value is one of the selected options
+	ShowIfAnswered  = "answered"   // Any answer was given
+)
+
+// ShowIfCondition makes a question or section depend on the answer to an
+// earlier question
+type ShowIfCondition struct {
+	QuestionIndex int    `bson:"questionIndex" json:"questionIndex"`
+	Operator      string `bson:"operator" json:"operator"`
+	Value         string `bson:"value,omitempty" json:"value,omitempty"`
+}
+
+// FormSection is a page of a form. It is only shown when all its conditions hold.
+type FormSection struct {
+	Title       string            `bson:"title" json:"title"`
+	Description string            `bson:"description,omitempty" json:"description,omitempty"`
+	ShowIf      []ShowIfCondition `bson:"showIf,omitempty" json:"showIf,omitempty"`
 }
 
 type FormQuestion struct {
-	Question  string   `bson:"question" json:"question"`
-	InputType string   `bson:"inputType" json:"inputType"`
-	MaxSel    string   `bson:"maxSel,omitempty" json:"maxSel,omitempty"`
-	Options   []string `bson:"options" json:"options"`
+	Question  string            `bson:"question" json:"question"`
+	InputType string            `bson:"inputType" json:"inputType"`
+	MaxSel    string            `bson:"maxSel,omitempty" json:"maxSel,omitempty"`
+	Options   []string          `bson:"options" json:"options"`
+	Required  bool              `bson:"required,omitempty" json:"required,omitempty"`
+	Min       *float64          `bson:"min,omitempty" json:"min,omitempty"` // Value for numbers, length for text, selections for checkboxes
+	Max       *float64          `bson:"max,omitempty" json:"max,omitempty"`
+	HelpText  string            `bson:"helpText,omitempty" json:"helpText,omitempty"`
+	Section   int               `bson:"section,omitempty" json:"section,omitempty"` // Index in formSections
+	ShowIf    []ShowIfCondition `bson:"showIf,omitempty" json:"showIf,omitempty"`   // All must hold for the question to be shown
+
+	// Answer key of quiz forms, only sent to organizers
+	CorrectAnswers []string `bson:"correctAnswers,omitempty" json:"correctAnswers,omitempty"`
+	Points         float64  `bson:"points,omitempty" json:"points,omitempty"` // 1 when not set
+
+	// Limits of file questions, the server defaults apply when not set
+	MaxFileSize  int64    `bson:"maxFileSize,omitempty" json:"maxFileSize,omitempty"`   // Bytes
+	AllowedTypes []string `bson:"allowedTypes,omitempty" json:"allowedTypes,omitempty"` // MIME types such as "application/pdf" or "image/*"
+}
+
+// Who besides the organizers can see the results of a vote or form
+const (
+	ResultsVisibleNever      = "never" // default
+	ResultsVisibleDuring     = "during"
+	ResultsVisibleAfterClose = "after_close"
+)
+
+// DeadlineExtension moves the end date of a post for one student
+type DeadlineExtension struct {
+	StudentID string             `bson:"studentID" json:"studentID"`
+	EndDate   primitive.DateTime `bson:"endDate" json:"endDate"`
 }
 
 // Post represents a general post.
 type Post struct {
-	PostID        string              `bson:"postID,omitempty" json:"postID,omitempty"` //use get postID in string format
-	ID            primitive.ObjectID  `bson:"_id" json:"_id"`
-	Kind          string              `bson:"kind" json:"kind"`
-	AssignTo      []string            `bson:"assignTo" json:"assignTo"`
-	Public        bool                `bson:"public" json:"public"`
-	Title         string              `bson:"title" json:"title"`
-	Description   string              `bson:"description" json:"description"`
-	PostDate      primitive.DateTime  `bson:"postDate" json:"postDate"`
-	EndDate       *primitive.DateTime `bson:"endDate" json:"endDate,omitempty"` // Nullable
-	Author        string              `bson:"author" json:"author"`
-	Markdown      string              `bson:"markdown,omitempty" json:"markdown,omitempty"`
-	FormQuestions []FormQuestion      `bson:"formQuestions,omitempty" json:"formQuestions,omitempty"` // For form posts
-	VoteQuestions VoteQuestion        `bson:"voteQuestions,omitempty" json:"voteQuestions,omitempty"` // For vote posts
+	PostID            string              `bson:"postID,omitempty" json:"postID,omitempty"` //use get postID in string format
+	ID                primitive.ObjectID  `bson:"_id" json:"_id"`
+	Kind              string              `bson:"kind" json:"kind"`
+	AssignTo          []string            `bson:"assignTo" json:"assignTo"`
+	Public            bool                `bson:"public" json:"public"`
+	Title             string              `bson:"title" json:"title"`
+	Description       string              `bson:"description" json:"description"`
+	PostDate          primitive.DateTime  `bson:"postDate" json:"postDate"`
+	EndDate           *primitive.DateTime `bson:"endDate" json:"endDate,omitempty"`                               // Nullable
+	PublishAt         *primitive.DateTime `bson:"publishAt,omitempty" json:"publishAt,omitempty"`                 // Hidden from students until then, nullable for right away
+	HideAt            *primitive.DateTime `bson:"hideAt,omitempty" json:"hideAt,omitempty"`                       // Hidden from students again from then, nullable
+	Announced         *bool               `bson:"announced,omitempty" json:"-"`                                   // False until members are notified of the publication
+	GracePeriod       int                 `bson:"gracePeriod,omitempty" json:"gracePeriod,omitempty"`             // Minutes answers are still accepted after endDate
+	Anonymous         bool                `bson:"anonymous,omitempty" json:"anonymous,omitempty"`                 // Secret ballot, vote posts only, fixed at creation
+	Quiz              bool                `bson:"quiz,omitempty" json:"quiz,omitempty"`                           // Form posts graded against the answer key
+	ResultsVisibility string              `bson:"resultsVisibility,omitempty" json:"resultsVisibility,omitempty"` // One of the ResultsVisible values
+	Extensions        []DeadlineExtension `bson:"extensions,omitempty" json:"-"`                                  // Managed through the extension endpoints
+	CommentsLocked    bool                `bson:"commentsLocked,omitempty" json:"commentsLocked,omitempty"`       // Set by moderators, no new comments
+	Author            string              `bson:"author" json:"author"`
+	Markdown          string              `bson:"markdown,omitempty" json:"markdown,omitempty"`
+	FormQuestions     []FormQuestion      `bson:"formQuestions,omitempty" json:"formQuestions,omitempty"` // For form posts
+	FormSections      []FormSection       `bson:"formSections,omitempty" json:"formSections,omitempty"`   // Pages of a form post, questions are on the first page without them
+	VoteQuestions     VoteQuestion        `bson:"voteQuestions,omitempty" json:"voteQuestions,omitempty"` // For vote posts
+	Ballot            []VoteQuestion      `bson:"ballot,omitempty" json:"ballot,omitempty"`               // For vote posts with several questions, replaces voteQuestions
+	Assignees         []string            `bson:"assignees,omitempty" json:"assignees,omitempty"`         // For task posts, students assigned by name besides the assignTo roles
+	Reminded          bool                `bson:"reminded,omitempty" json:"-"`                            // Task posts whose overdue assignees were reminded
+
+	// Post history, both are 0 on posts from before it was kept
+	Revision         int `bson:"revision,omitempty" json:"revision,omitempty"`                 // Latest revision
+	QuestionsVersion int `bson:"questionsVersion,omitempty" json:"questionsVersion,omitempty"` // Raised by edits of the form questions, 0 counts as 1
 }
 
 // PPost extends Post for regular posts.
@@ -53,6 +142,12 @@ type PForm struct {
 	TimeUp    bool           `bson:"timeUp" json:"timeUp"`
 }
 
+// PTask extends Post for task posts, whose due date is the end date
+type PTask struct {
+	Post
+	TimeUp bool `bson:"timeUp" json:"timeUp"`
+}
+
 type CreatePostRequest struct {
 	EventID     primitive.ObjectID `bson:"eventID" json:"eventID"`
 	UpdatedPost Post               `bson:"updatedPost" json:"updatedPost"`
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the post history
+const (
+	PostActionCreate  = "create"
+	PostActionEdit    = "edit"
+	PostActionRestore = "restore"
+)
+
+// FieldChange is one field of a post as it was before and after an edit
+type FieldChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// PostRevision is a version of a post, with the changes from the version before
+type PostRevision struct {
+	ID               primitive.ObjectID `bson:"_id" json:"_id"`
+	PostID           primitive.ObjectID `bson:"postID" json:"postID"`
+	Revision         int                `bson:"revision" json:"revision"`
+	QuestionsVersion int                `bson:"questionsVersion" json:"questionsVersion"`
+	Action           string             `bson:"action" json:"action"`
+	Author           string             `bson:"author" json:"author"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
+	RestoredFrom     int                `bson:"restoredFrom,omitempty" json:"restoredFrom,omitempty"` // Revision brought back by a restore
+	Changes          []FieldChange      `bson:"changes" json:"changes"`
+	Post             *Post              `bson:"post,omitempty" json:"post,omitempty"` // Content of the post at this revision
+}
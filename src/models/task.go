@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Progress of an assignee on a task
+const (
+	TaskTodo       = "todo" // default, also for assignees who never reported
+	TaskInProgress = "in_progress"
+	TaskDone       = "done"
+)
+
+// TaskProgress is where one assignee is with a task post
+type TaskProgress struct {
+	ID          primitive.ObjectID `bson:"_id" json:"_id"`
+	PostID      primitive.ObjectID `bson:"postID" json:"postID"`
+	StudentID   string             `bson:"studentID" json:"studentID"`
+	Status      string             `bson:"status" json:"status"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	CompletedAt *time.Time         `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attendance values in a transcript entry
+const (
+	AttendanceAttended    = "attended"
+	AttendanceAbsent      = "absent"
+	AttendanceNotRecorded = "not_recorded" // no check-ins were taken for the event
+)
+
+// TranscriptEntry is one event a student joined
+type TranscriptEntry struct {
+	EventID     primitive.ObjectID `json:"eventID"`
+	EventName   string             `json:"eventName"`
+	Kind        string             `json:"kind"`
+	Role        string             `json:"role"` // "participant" or "staff"
+	StaffRole   string             `json:"staffRole,omitempty"`
+	StartDate   time.Time          `json:"startDate"`
+	EndDate     time.Time          `json:"endDate"`
+	Status      string             `json:"status"`
+	Hours       float64            `json:"hours"`
+	Attendance  string             `json:"attendance"`
+	CheckedInAt *time.Time         `json:"checkedInAt,omitempty"`
+}
+
+// Transcript is the co-curricular record of a student
+type Transcript struct {
+	StudentID     string            `json:"studentID"`
+	Name          string            `json:"name"`
+	Year          int               `json:"year"`
+	GeneratedAt   time.Time         `json:"generatedAt"`
+	TotalEvents   int               `json:"totalEvents"`
+	AttendedCount int               `json:"attendedCount"`
+	TotalHours    float64           `json:"totalHours"`    // every joined event that is over
+	AttendedHours float64           `json:"attendedHours"` // events over with a recorded check-in
+	Entries       []TranscriptEntry `json:"entries"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upload is a file sent for a file question of a form. The answer to the
+// question holds the hex IDs of its uploads.
+type Upload struct {
+	ID            primitive.ObjectID `bson:"_id" json:"_id"`
+	Key           string             `bson:"key" json:"-"` // Location in the storage backend
+	PostID        primitive.ObjectID `bson:"postID" json:"postID"`
+	QuestionIndex int                `bson:"questionIndex" json:"questionIndex"`
+	StudentID     string             `bson:"studentID" json:"studentID"`
+	FileName      string             `bson:"fileName" json:"fileName"`
+	ContentType   string             `bson:"contentType" json:"contentType"`
+	Size          int64              `bson:"size" json:"size"`
+	UploadedAt    time.Time          `bson:"uploadedAt" json:"uploadedAt"`
+}
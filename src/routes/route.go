@@ -16,9 +16,14 @@ func UserRoutes(route *gin.RouterGroup) {
 		c.JSON(http.StatusOK, gin.H{"message": "Hello World"})
 	})
 
-	v1.GET("/events", controllers.GetEvents())
-	v1.GET("/searchEvents", controllers.SearchEvents()) //usage: /searchEvents?name=XXXXXX
+	v1.GET("/events", middleware.OptionalAuthentication(), controllers.GetEvents())
+	v1.GET("/searchEvents", middleware.OptionalAuthentication(), controllers.SearchEvents()) //usage: /searchEvents?name=XXXXXX
 	// v1.GET("/event/:eventID/posts", controllers.GetPostFromEvent())
+	v1.GET("/calendar/events.ics", controllers.GetPublicCalendar())
+	v1.GET("/calendar/feed/:token", controllers.GetPersonalCalendar()) //usage: /calendar/feed/<token>.ics
+	v1.GET("/files/:token", controllers.DownloadFile())                // Links from posts/upload/:uploadID
+	v1.GET("/attachments/:token", controllers.DownloadAttachment())    // Links from posts/attachment/:attachmentID
+	v1.GET("/media/:mediaID", controllers.GetMedia())                  //usage: /media/<mediaID>?size=full|thumb
 
 	// Group routes for user related operations
 	userRoute := v1.Group("/user")
@@ -39,22 +44,62 @@ func UserRoutes(route *gin.RouterGroup) {
 		protected.GET("/event/:eventID/posts", controllers.GetPostFromEvent())
 		protected.GET("/event/:eventID/members", controllers.GetEventMembers())
 		protected.GET("/event/allRole/:eventID", controllers.GetAllRole())
+		protected.GET("/event/:eventID/waitlist", controllers.GetWaitlist())
+		protected.GET("/event/:eventID/checkin/token", controllers.GetCheckInToken())
+		protected.GET("/event/:eventID/checkin/qr", controllers.GetCheckInQR())
+		protected.POST("/event/checkin/scan", controllers.ScanCheckIn())
+		protected.GET("/event/:eventID/attendance", controllers.GetAttendance())
+		protected.GET("/event/:eventID/tasks", controllers.GetTaskDashboard())
 
 		profile := protected.Group("/account")
 		profile.GET("", controllers.GetInfo())
 		profile.PATCH("", controllers.UpdateInfo())
 		profile.GET("/profile", controllers.GetProfile())
 		profile.PATCH("/profile", controllers.UpdateProfile())
+		profile.GET("/transcript", controllers.GetMyTranscript()) //usage: /account/transcript?format=json|csv|pdf
+		profile.GET("/calendar", controllers.GetCalendarToken())
+		profile.POST("/calendar", controllers.RotateCalendarToken())
+		profile.DELETE("/calendar", controllers.RevokeCalendarToken())
+		profile.GET("/notifications", controllers.GetNotifications()) //usage: /account/notifications?unread=true&limit=50
+		profile.PATCH("/notifications/:notificationID/read", controllers.MarkNotificationRead())
+		profile.POST("/notifications/read", controllers.MarkAllNotificationsRead())
 
 		protected.PATCH("/event/join", controllers.JoinEvent())
 		protected.PATCH("/event/leave", controllers.LeaveEvent())
 		protected.GET("posts/:postID", controllers.GetPostFromPostId())
 		protected.POST("/posts/create", controllers.CreateNewPost())
 		protected.PATCH("/posts/update", controllers.UpdatePost())
+		protected.GET("posts/history/:postID", controllers.GetPostHistory())
+		protected.GET("posts/history/:postID/:revision", controllers.GetPostRevision())
+		protected.POST("posts/history/:postID/:revision/restore", controllers.RestorePostRevision())
 		protected.DELETE("/posts/delete", controllers.DeletePost())
 		protected.POST("posts/submit", controllers.SubmitAnswer())
+		protected.PATCH("posts/answer", controllers.EditAnswer())
+		protected.PUT("posts/draft", controllers.SaveDraft()) // Finalized by posts/submit
+		protected.DELETE("posts/answer/:postID", controllers.WithdrawAnswer())
+		protected.GET("posts/extension/:postID", controllers.GetExtensions())
+		protected.PUT("posts/extension", controllers.SetExtension())
+		protected.DELETE("posts/extension/:postID/:studentID", controllers.DeleteExtension())
 		protected.GET("posts/answer/:postID/:studentID", controllers.GetUserAnswer())
 		protected.GET("posts/summary/:postID", controllers.GetSummaryAnswer())
+		protected.GET("posts/summary/:postID/stream", controllers.StreamSummaryAnswer()) // Server-Sent Events
+		protected.GET("posts/export/:postID", controllers.ExportAnswers())               //usage: /posts/export/<postID>?format=csv|xlsx
+		protected.GET("posts/ballot/:postID/:receipt", controllers.VerifyBallot())
+		protected.GET("posts/quiz/:postID", controllers.GetQuizResults())
+		protected.POST("posts/upload/:postID/:questionIndex", controllers.UploadAnswerFile())
+		protected.GET("posts/upload/:uploadID", controllers.GetUploadLink())
+		protected.GET("posts/attachments/:postID", controllers.GetAttachments())
+		protected.POST("posts/attachments/:postID", controllers.UploadAttachment())
+		protected.GET("posts/attachment/:attachmentID", controllers.GetAttachmentLink())
+		protected.DELETE("posts/attachment/:attachmentID", controllers.DeleteAttachment())
+		protected.GET("posts/comments/:postID", controllers.GetComments())
+		protected.POST("posts/comments/:postID", controllers.CreateComment())
+		protected.PATCH("posts/comments/:postID/lock", controllers.LockComments())
+		protected.PATCH("posts/comment/:commentID", controllers.EditComment())
+		protected.DELETE("posts/comment/:commentID", controllers.DeleteComment())
+		protected.PATCH("posts/comment/:commentID/hide", controllers.HideComment())
+		protected.GET("posts/task/:postID", controllers.GetTaskProgress())
+		protected.PUT("posts/task/:postID/status", controllers.UpdateTaskStatus())
 
 	}
 
@@ -65,6 +110,15 @@ func UserRoutes(route *gin.RouterGroup) {
 		})
 		protected.POST("/event/create", controllers.CreateNewEvent())
 		protected.PATCH("/event/updateEvent", controllers.UpdateEvent())
+		protected.PATCH("/event/status", controllers.UpdateEventStatus())
 		protected.DELETE("/event/deleteEvent/:eventID", controllers.DeleteEvent())
+		protected.PUT("/event/:eventID/media/:kind", controllers.UploadEventMedia()) // kind is icon or poster
+	}
+
+	// Group routes for the department (access level 3)
+	admin := v1.Group("/admin")
+	admin.Use(middleware.Authentication(3))
+	{
+		admin.GET("/transcript/:studentID", controllers.GetStudentTranscript())
 	}
 }
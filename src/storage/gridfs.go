@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFS stores files in MongoDB, the key is used as the file ID
+type GridFS struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFS(db *mongo.Database, bucketName string) (*GridFS, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFS{bucket: bucket}, nil
+}
+
+func (g *GridFS) Save(ctx context.Context, key string, r io.Reader) error {
+	return g.bucket.UploadFromStreamWithID(key, key, r)
+}
+
+func (g *GridFS) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	stream, err := g.bucket.OpenDownloadStream(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (g *GridFS) Delete(ctx context.Context, key string) error {
+	err := g.bucket.DeleteContext(ctx, key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil
+	}
+	return err
+}
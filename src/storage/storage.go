@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/encall/cpeevent-backend/src/database"
+)
+
+// ErrNotFound is returned when no file is stored under a key
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps uploaded files. Keys are generated by the caller and only
+// hold letters, digits, dashes and slashes.
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Backend is the storage selected by STORAGE_BACKEND, "local" (default) or "gridfs"
+var Backend Storage = openBackend()
+
+func openBackend() Storage {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "gridfs":
+		backend, err := NewGridFS(database.Client.Database(os.Getenv("DATABASE_NAME")), "uploads")
+		if err != nil {
+			log.Fatal(err)
+		}
+		return backend
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocal(dir)
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q", os.Getenv("STORAGE_BACKEND"))
+		return nil
+	}
+}